@@ -0,0 +1,298 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// AnonFlags is the type used for selecting what will be anonymized
+// (see AnonymizeMsg() and AnonymizeURI()).
+type AnonFlags uint8
+
+// AnonFlags values.
+const (
+	AnonUserF   AnonFlags = 1 << iota // replace URI user parts with a hash
+	AnonHostIPF                       // mask URI hosts that are IPs
+	AnonCallIDF                       // mask IPs embedded in the Call-ID
+	AnonTagsF                         // replace From & To tags with a hash
+)
+
+// AnonAllF selects all the supported anonymizations.
+const AnonAllF = AnonUserF | AnonHostIPF | AnonCallIDF | AnonTagsF
+
+// AnonCfg contains the anonymization configuration.
+type AnonCfg struct {
+	Flags AnonFlags // what should be anonymized
+	Salt  []byte    // salt used when hashing (user parts and tags)
+}
+
+// AnonHashLen is the length of the hash replacing user parts and tags.
+const AnonHashLen = 16
+
+// AnonMaskChr is the character used for masking the ip digits.
+const AnonMaskChr = 'x'
+
+// anonymization operations
+const (
+	anonOpHash uint8 = iota // replace with a salted hash
+	anonOpMask              // mask digits, keep the rest
+)
+
+// anonRegion is a part of the message that will be re-written.
+type anonRegion struct {
+	f  PField
+	op uint8
+}
+
+// anonRegions holds all the message regions that need re-writing.
+type anonRegions []anonRegion
+
+func (r *anonRegions) add(offs, l int, op uint8) {
+	if l <= 0 {
+		return
+	}
+	var f PField
+	f.Set(offs, offs+l)
+	*r = append(*r, anonRegion{f: f, op: op})
+}
+
+// addURI adds the parts of an unparsed uri that need anonymization.
+// uri is the PField pointing to the uri in buf.
+// It returns false if the uri cannot be parsed (and so it cannot be
+// anonymized).
+func (r *anonRegions) addURI(buf []byte, uri PField, flags AnonFlags) bool {
+	var pu PsipURI
+	if uri.Empty() {
+		return true
+	}
+	if err, _ := ParseURI(uri.Get(buf), &pu); err != NoURIErr {
+		return false
+	}
+	if flags&AnonUserF != 0 {
+		r.add(int(uri.Offs+pu.User.Offs), int(pu.User.Len), anonOpHash)
+	}
+	if flags&AnonHostIPF != 0 && anonIsIP(pu.Host.Get(uri.Get(buf))) {
+		r.add(int(uri.Offs+pu.Host.Offs), int(pu.Host.Len), anonOpMask)
+	}
+	return true
+}
+
+// addRoutes adds the uri parts that need anonymization for all the values
+// of the Route or Record-Route header h.
+// It returns false if a value cannot be parsed.
+func (r *anonRegions) addRoutes(buf []byte, h *Hdr, flags AnonFlags) bool {
+	o := int(h.Val.Offs)
+	for {
+		var pf PFromBody
+		next, err := ParseNameAddrPVal(h.Type, buf, o, &pf)
+		if err != 0 && err != ErrHdrMoreValues {
+			return false
+		}
+		if !r.addURI(buf, pf.URI, flags) {
+			return false
+		}
+		if err == 0 {
+			return true
+		}
+		o = next
+	}
+}
+
+// addIPs adds all the ipv4 and ipv6 addresses contained in f.
+func (r *anonRegions) addIPs(buf []byte, f PField) {
+	b := f.Get(buf)
+	for o := 0; o < len(b); {
+		ok4, offs4, len4 := ContainsIP4(b[o:], nil)
+		ok6, offs6, len6 := ContainsIP6(b[o:], nil)
+		var offs, l int
+		if ok4 && (!ok6 || offs4 <= offs6) {
+			offs, l = offs4, len4
+		} else if ok6 {
+			offs, l = offs6, len6
+		}
+		if l == 0 {
+			break
+		}
+		r.add(int(f.Offs)+o+offs, l, anonOpMask)
+		o += offs + l
+	}
+}
+
+// anonIsIP returns true if h is an ipv4 or ipv6 address.
+func anonIsIP(h []byte) bool {
+	if len(h) == 0 {
+		return false
+	}
+	if ok, n, err := IP4Prefix(h, nil); ok && err == ErrHdrOk && n == len(h) {
+		return true
+	}
+	if ok, n, err := IP6Prefix(h, nil); ok && err == ErrHdrOk && n == len(h) {
+		return true
+	}
+	return false
+}
+
+// anonWrite writes src anonymized according to op at dst[n:].
+// It returns the new offset in dst and true on success or false if
+// there is not enough space.
+func anonWrite(dst []byte, n int, src []byte, op uint8, salt []byte) (int, bool) {
+	const hextable = "0123456789abcdef"
+	switch op {
+	case anonOpHash:
+		if n+AnonHashLen > len(dst) {
+			return n, false
+		}
+		h := fnv.New64a()
+		h.Write(salt)
+		h.Write(src)
+		v := h.Sum64()
+		for i := AnonHashLen - 1; i >= 0; i-- {
+			dst[n+i] = hextable[v&0xf]
+			v >>= 4
+		}
+		return n + AnonHashLen, true
+	case anonOpMask:
+		if n+len(src) > len(dst) {
+			return n, false
+		}
+		for i, c := range src {
+			if hexDigToI(c) >= 0 {
+				dst[n+i] = AnonMaskChr
+			} else {
+				dst[n+i] = c
+			}
+		}
+		return n + len(src), true
+	}
+	return n, false
+}
+
+// anonCopy copies buf[start:end] into dst[n:], replacing all the
+// regions (which must be sorted and must point inside buf[start:end]).
+// It returns the number of bytes written in dst and true on success,
+// or false if dst is too small.
+func anonCopy(dst []byte, buf []byte, start, end int, regs anonRegions,
+	salt []byte) (int, bool) {
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].f.Offs < regs[j].f.Offs
+	})
+	n := 0
+	o := start
+	var ok bool
+	for _, r := range regs {
+		rstart := int(r.f.Offs)
+		rend := rstart + int(r.f.Len)
+		if rstart < o || rend > end {
+			// overlapping or outside the copied range => ignore
+			continue
+		}
+		if n+(rstart-o) > len(dst) {
+			return n, false
+		}
+		n += copy(dst[n:], buf[o:rstart])
+		if n, ok = anonWrite(dst, n, r.f.Get(buf), r.op, salt); !ok {
+			return n, false
+		}
+		o = rend
+	}
+	if n+(end-o) > len(dst) {
+		return n, false
+	}
+	n += copy(dst[n:], buf[o:end])
+	return n, true
+}
+
+// AnonymizeMsg writes an anonymized copy of the raw message m into dst.
+// What is anonymized is controlled by cfg: the user parts of the request
+// uri, From, To, Contact, P-Asserted-Identity, Route and Record-Route uris
+// are replaced by a salted hash (AnonUserF), the same uri hosts that are
+// IPs and all the IPs in the Via headers (sent-by, received or maddr) are
+// masked (AnonHostIPF), IPs embedded in the Call-ID are masked
+// (AnonCallIDF) and the From and To tags are replaced by a salted hash
+// (AnonTagsF).
+// Using the same salt, the same value will always be replaced with the
+// same hash, so anonymized messages can still be correlated.
+// The message body is copied as it is.
+// It returns the number of bytes written into dst and true on success, or
+// false if the message is not fully parsed, if dst is too small or if
+// some value that should be anonymized is not available: an uri that
+// cannot be parsed, contacts or P-Asserted-Identity values that did not fit
+// in m.PV (see PContacts.More()) or headers that did not fit in m.HL.Hdrs
+// (see PSIPMsg.HeadersTruncated()).
+func AnonymizeMsg(dst []byte, m *PSIPMsg, cfg *AnonCfg) (int, bool) {
+	if !m.Parsed() {
+		return 0, false
+	}
+	buf := m.Buf
+	regs := make(anonRegions, 0, 8)
+	if cfg.Flags&(AnonUserF|AnonHostIPF) != 0 {
+		// values that were not parsed cannot be anonymized
+		if m.PV.Contacts.More() || m.PV.PAIs.More() ||
+			m.HeadersTruncated() {
+			return 0, false
+		}
+		if m.Request() && !regs.addURI(buf, m.FL.URI, cfg.Flags) {
+			return 0, false
+		}
+		if !regs.addURI(buf, m.PV.From.URI, cfg.Flags) ||
+			!regs.addURI(buf, m.PV.To.URI, cfg.Flags) {
+			return 0, false
+		}
+		for i := 0; i < m.PV.Contacts.VNo(); i++ {
+			if !m.PV.Contacts.Vals[i].Star &&
+				!regs.addURI(buf, m.PV.Contacts.Vals[i].URI, cfg.Flags) {
+				return 0, false
+			}
+		}
+		for i := 0; i < m.PV.PAIs.VNo(); i++ {
+			if !regs.addURI(buf, m.PV.PAIs.Vals[i].URI, cfg.Flags) {
+				return 0, false
+			}
+		}
+		for i := 0; i < m.HL.N; i++ {
+			h := &m.HL.Hdrs[i]
+			switch h.Type {
+			case HdrRoute, HdrRecordRoute:
+				if !regs.addRoutes(buf, h, cfg.Flags) {
+					return 0, false
+				}
+			case HdrVia:
+				if cfg.Flags&AnonHostIPF != 0 {
+					regs.addIPs(buf, h.Val)
+				}
+			}
+		}
+	}
+	if cfg.Flags&AnonTagsF != 0 {
+		regs.add(int(m.PV.From.Tag.Offs), int(m.PV.From.Tag.Len), anonOpHash)
+		regs.add(int(m.PV.To.Tag.Offs), int(m.PV.To.Tag.Len), anonOpHash)
+	}
+	if cfg.Flags&AnonCallIDF != 0 {
+		regs.addIPs(buf, m.PV.Callid.CallID)
+	}
+	start := len(buf) - len(m.RawMsg)
+	return anonCopy(dst, buf, start, len(buf), regs, cfg.Salt)
+}
+
+// AnonymizeURI writes an anonymized copy of the complete parsed uri u
+// (see PsipURI.Long()) into dst. buf is the buffer u points into.
+// Only AnonUserF and AnonHostIPF from cfg are used.
+// It returns the number of bytes written and true on success or false if
+// dst is too small.
+func AnonymizeURI(dst []byte, buf []byte, u *PsipURI, cfg *AnonCfg) (int, bool) {
+	l := u.Long()
+	regs := make(anonRegions, 0, 2)
+	if cfg.Flags&AnonUserF != 0 {
+		regs.add(int(u.User.Offs), int(u.User.Len), anonOpHash)
+	}
+	if cfg.Flags&AnonHostIPF != 0 && anonIsIP(u.Host.Get(buf)) {
+		regs.add(int(u.Host.Offs), int(u.Host.Len), anonOpMask)
+	}
+	return anonCopy(dst, buf, int(l.Offs), int(l.Offs+l.Len), regs, cfg.Salt)
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAnonymizeMsg(t *testing.T) {
+	buf := unescapeCRLF(`INVITE sip:bob@192.168.1.2:5060 SIP/2.0\r
+Via: SIP/2.0/UDP 172.16.1.1:5060;branch=z9hG4bK1;received=172.16.1.2\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK2\r
+Route: <sip:carol@172.16.1.3;lr>, <sip:proxy.atlanta.com;lr>\r
+Record-Route: <sip:172.16.1.4;lr>\r
+From: "Alice" <sip:alice@atlanta.com>;tag=1928301774\r
+To: <sip:bob@biloxi.com>\r
+Call-ID: a84b4c76e66710@10.0.0.1\r
+CSeq: 314159 INVITE\r
+Contact: <sip:alice@[2001:db8::1]>\r
+Content-Length: 4\r
+\r
+test`)
+	var msg PSIPMsg
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+
+	type testCase struct {
+		flags AnonFlags
+		keep  []string // expected to be present
+		gone  []string // expected to be removed
+	}
+	tests := [...]testCase{
+		{AnonAllF,
+			[]string{"@xxx.xxx.x.x:5060", "@atlanta.com",
+				"a84b4c76e66710@xx.x.x.x", "@[xxxx:xxx::x]", "\r\n\r\ntest",
+				"UDP xxx.xx.x.x:5060;", "received=xxx.xx.x.x",
+				"UDP pc33.atlanta.com;", "@xxx.xx.x.x;lr", "<sip:proxy.atlanta",
+				"<sip:xxx.xx.x.x;lr>"},
+			[]string{"bob", "alice", "carol", "1928301774", "10.0.0.1",
+				"2001:db8::1", "192.168.1.2", "172.16"}},
+		{AnonUserF,
+			[]string{"@192.168.1.2:5060", "1928301774", "@10.0.0.1",
+				"@[2001:db8::1]", "172.16.1.1:5060", "@172.16.1.3;lr"},
+			[]string{"bob", "alice", "carol"}},
+		{AnonCallIDF,
+			[]string{"bob@192.168.1.2", "alice@atlanta.com", "1928301774",
+				"a84b4c76e66710@xx.x.x.x"},
+			[]string{"10.0.0.1"}},
+		{AnonTagsF,
+			[]string{"bob@192.168.1.2", "alice@atlanta.com", "@10.0.0.1"},
+			[]string{"1928301774"}},
+	}
+	for _, tc := range tests {
+		cfg := AnonCfg{Flags: tc.flags, Salt: []byte("salt")}
+		dst := make([]byte, 2*len(buf))
+		n, ok := AnonymizeMsg(dst, &msg, &cfg)
+		if !ok {
+			t.Fatalf("AnonymizeMsg(%q, 0x%x) failed", buf, tc.flags)
+		}
+		res := dst[:n]
+		for _, s := range tc.keep {
+			if !bytes.Contains(res, []byte(s)) {
+				t.Errorf("AnonymizeMsg(0x%x): %q not found in %q",
+					tc.flags, s, res)
+			}
+		}
+		for _, s := range tc.gone {
+			if bytes.Contains(res, []byte(s)) {
+				t.Errorf("AnonymizeMsg(0x%x): %q still present in %q",
+					tc.flags, s, res)
+			}
+		}
+		// same salt => same result
+		dst2 := make([]byte, n)
+		if n2, ok := AnonymizeMsg(dst2, &msg, &cfg); !ok || n2 != n ||
+			!bytes.Equal(dst2, res) {
+			t.Errorf("AnonymizeMsg(0x%x): not deterministic: %q != %q",
+				tc.flags, dst2[:n2], res)
+		}
+		// dst too small
+		if _, ok := AnonymizeMsg(dst[:n-1], &msg, &cfg); ok {
+			t.Errorf("AnonymizeMsg(0x%x): succeeded with a too small dst",
+				tc.flags)
+		}
+	}
+}
+
+// values that did not fit in the parsed message cannot be anonymized
+func TestAnonymizeMsgTruncated(t *testing.T) {
+	buf := unescapeCRLF(`REGISTER sip:registrar.biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP 192.0.2.4:5060;branch=z9hG4bKnashds7\r
+From: <sip:bob@biloxi.com>;tag=456248\r
+To: <sip:bob@biloxi.com>\r
+Call-ID: 843817637684230@998sdasdh09\r
+CSeq: 1826 REGISTER\r
+Contact: <sip:bob@192.0.2.4>, <sip:bob@192.0.2.5>\r
+\r
+`)
+	type testCase struct {
+		hdrs     int // headers space
+		contacts int // contacts space
+		flags    AnonFlags
+		ok       bool
+	}
+	tests := [...]testCase{
+		{10, 10, AnonAllF, true},
+		{10, 1, AnonUserF, false},
+		{10, 1, AnonHostIPF, false},
+		{10, 1, AnonTagsF | AnonCallIDF, true},
+		{3, 10, AnonHostIPF, false},
+		{3, 10, AnonTagsF, true},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		msg.Init(buf, make([]Hdr, tc.hdrs), make([]PFromBody, tc.contacts))
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		cfg := AnonCfg{Flags: tc.flags, Salt: []byte("salt")}
+		dst := make([]byte, 2*len(buf))
+		n, ok := AnonymizeMsg(dst, &msg, &cfg)
+		if ok != tc.ok {
+			t.Errorf("AnonymizeMsg(0x%x, %d hdrs, %d contacts) = %v,"+
+				" expected %v", tc.flags, tc.hdrs, tc.contacts, ok, tc.ok)
+		}
+		if ok && tc.flags&AnonHostIPF != 0 &&
+			bytes.Contains(dst[:n], []byte("192.0.2")) {
+			t.Errorf("AnonymizeMsg(0x%x): IP still present in %q",
+				tc.flags, dst[:n])
+		}
+	}
+
+	// unparsable uri
+	bad := bytes.Replace(buf, []byte("<sip:bob@192.0.2.5>"),
+		[]byte("<foo:bob@192.0.2.5>"), 1)
+	var msg PSIPMsg
+	msg.Init(bad, nil, nil)
+	if _, err := ParseSIPMsg(bad, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", bad, err, err)
+	}
+	cfg := AnonCfg{Flags: AnonAllF}
+	if _, ok := AnonymizeMsg(make([]byte, 2*len(bad)), &msg, &cfg); ok {
+		t.Errorf("AnonymizeMsg(%q) succeeded with an unparsable uri", bad)
+	}
+}
+
+func TestAnonymizeURI(t *testing.T) {
+	type testCase struct {
+		uri   string
+		flags AnonFlags
+		res   string
+	}
+	tests := [...]testCase{
+		{"sip:foo@1.2.3.4:5060;transport=tcp", AnonHostIPF,
+			"sip:foo@x.x.x.x:5060;transport=tcp"},
+		{"sip:foo@bar.com", AnonHostIPF, "sip:foo@bar.com"},
+		{"sip:bar.com", AnonUserF, "sip:bar.com"},
+		{"sip:foo:pass@[::1]", AnonHostIPF, "sip:foo:pass@[::x]"},
+	}
+	for _, tc := range tests {
+		var u PsipURI
+		b := []byte(tc.uri)
+		if err, _ := ParseURI(b, &u); err != NoURIErr {
+			t.Fatalf("ParseURI(%q) failed: %d (%q)", b, err, err)
+		}
+		dst := make([]byte, 100)
+		cfg := AnonCfg{Flags: tc.flags}
+		n, ok := AnonymizeURI(dst, b, &u, &cfg)
+		if !ok || string(dst[:n]) != tc.res {
+			t.Errorf("AnonymizeURI(%q, 0x%x) = %q, %v expected %q",
+				tc.uri, tc.flags, dst[:n], ok, tc.res)
+		}
+	}
+	// user hash
+	var u PsipURI
+	b := []byte("sip:foo@bar.com")
+	ParseURI(b, &u)
+	dst := make([]byte, 100)
+	n, ok := AnonymizeURI(dst, b, &u, &AnonCfg{Flags: AnonUserF})
+	if !ok || n != len(b)-len("foo")+AnonHashLen ||
+		bytes.Contains(dst[:n], []byte("foo")) ||
+		!bytes.HasSuffix(dst[:n], []byte("@bar.com")) {
+		t.Errorf("AnonymizeURI(%q, AnonUserF) = %q, %v", b, dst[:n], ok)
+	}
+}
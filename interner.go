@@ -0,0 +1,66 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"sync"
+)
+
+// Interner keeps shared copies of frequently repeated values (e.g.
+// User-Agent strings, domains or method names), so that storing them
+// does not require a new copy for each message or call.
+// The number of stored values is bounded (see NewInterner()). When full,
+// the values are copied, but not stored anymore.
+// It is safe for concurrent use.
+type Interner struct {
+	lock sync.Mutex
+	vals map[string]string
+	max  int
+}
+
+// NewInterner returns a new Interner storing at most max different
+// values.
+func NewInterner(max int) *Interner {
+	return &Interner{vals: make(map[string]string), max: max}
+}
+
+// Intern returns a shared string copy of b. If b was not seen before,
+// a new copy is made and stored (if there is space left).
+func (in *Interner) Intern(b []byte) string {
+	in.lock.Lock()
+	// no allocation for the map lookup with string(b)
+	s, ok := in.vals[string(b)]
+	if !ok {
+		s = string(b)
+		if len(in.vals) < in.max {
+			in.vals[s] = s
+		}
+	}
+	in.lock.Unlock()
+	return s
+}
+
+// Len returns the number of stored values.
+func (in *Interner) Len() int {
+	in.lock.Lock()
+	n := len(in.vals)
+	in.lock.Unlock()
+	return n
+}
+
+// Reset removes all the stored values.
+func (in *Interner) Reset() {
+	in.lock.Lock()
+	in.vals = make(map[string]string)
+	in.lock.Unlock()
+}
+
+// Intern returns a shared string copy of the field content (p points
+// inside buf), using the Interner in (see Interner.Intern()).
+func (p PField) Intern(buf []byte, in *Interner) string {
+	return in.Intern(p.Get(buf))
+}
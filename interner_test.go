@@ -0,0 +1,77 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"strconv"
+	"testing"
+	"unsafe"
+)
+
+// strData returns a pointer to the string data (used to check if 2
+// strings share the same memory).
+func strData(s string) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&s))
+}
+
+func TestInterner(t *testing.T) {
+	in := NewInterner(2)
+	buf := []byte("UA: Foo/1.0 Foo/1.0 Bar/2 Baz/3")
+	var f1, f2, f3, f4 PField
+	f1.Set(4, 11)
+	f2.Set(12, 19)
+	f3.Set(20, 25)
+	f4.Set(26, 31)
+
+	s1 := f1.Intern(buf, in)
+	s2 := f2.Intern(buf, in)
+	if s1 != "Foo/1.0" || s2 != "Foo/1.0" || strData(s1) != strData(s2) {
+		t.Errorf("Intern: %q %q not shared", s1, s2)
+	}
+	if s := f3.Intern(buf, in); s != "Bar/2" || in.Len() != 2 {
+		t.Errorf("Intern: %q, %d values", s, in.Len())
+	}
+	// full
+	if s := f4.Intern(buf, in); s != "Baz/3" || in.Len() != 2 {
+		t.Errorf("Intern full: %q, %d values", s, in.Len())
+	}
+	// the returned value must not reference buf
+	buf[4] = 'X'
+	if s1 != "Foo/1.0" {
+		t.Errorf("Intern: value changed with the buffer: %q", s1)
+	}
+	in.Reset()
+	if in.Len() != 0 {
+		t.Errorf("Reset: %d values", in.Len())
+	}
+}
+
+func BenchmarkInterner(b *testing.B) {
+	const ua = "SIPp/3.6.1-TLS-SCTP-PCAP"
+	bufs := make([][]byte, 100)
+	for i := range bufs {
+		bufs[i] = []byte("User-Agent: " + ua + "\r\nCall-ID: " +
+			strconv.Itoa(i) + "\r\n")
+	}
+	var f PField
+	f.Set(len("User-Agent: "), len("User-Agent: ")+len(ua))
+	vals := make([]string, len(bufs)) // "stored" values, one per call
+
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			vals[i%len(vals)] = string(f.Get(bufs[i%len(bufs)]))
+		}
+	})
+	b.Run("intern", func(b *testing.B) {
+		in := NewInterner(1024)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			vals[i%len(vals)] = f.Intern(bufs[i%len(bufs)], in)
+		}
+	})
+}
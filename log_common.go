@@ -16,17 +16,52 @@ import (
 var Log slog.Log = slog.New(slog.LERR, slog.LbackTraceL|slog.LlocInfoL,
 	slog.LStdErr)
 
+// Logger is the interface that must be implemented by an external logger
+// used for the package log messages (see SetLogger()).
+// slog.Log implements it.
+type Logger interface {
+	// L returns true if logging at level lev is enabled.
+	L(lev slog.LogLevel) bool
+	// LLog logs a message at level lev. callersSkip is the number of
+	// stack frames to skip when looking for the caller location (1 for the
+	// caller of WARN(), ERR(), BUG() or DBG()).
+	LLog(lev slog.LogLevel, callersSkip int, prefix string,
+		f string, a ...interface{})
+}
+
+// logger is the current logger, if nil Log is used.
+var logger Logger
+
+// SetLogger routes all the package log messages to l, allowing the
+// levels filtering and the output to be controlled by the caller
+// (e.g. by returning false from l.L(slog.LDBG) the debug messages will be
+// silenced).
+// A nil l restores the default (Log).
+// It is not safe to call it concurrently with any logging, so it should be
+// called only at init time.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// getLogger returns the current logger.
+func getLogger() Logger {
+	if logger != nil {
+		return logger
+	}
+	return &Log // pointer: no allocation and it sees level changes
+}
+
 // WARN is a shorthand for logging a warning message.
 func WARN(f string, a ...interface{}) {
-	Log.LLog(slog.LWARN, 1, "WARNING: sipsp: ", f, a...)
+	getLogger().LLog(slog.LWARN, 1, "WARNING: sipsp: ", f, a...)
 }
 
 // ERR is a shorthand for logging an error message.
 func ERR(f string, a ...interface{}) {
-	Log.LLog(slog.LERR, 1, "ERROR: sipsp: ", f, a...)
+	getLogger().LLog(slog.LERR, 1, "ERROR: sipsp: ", f, a...)
 }
 
 // BUG is a shorthand for logging a bug message.
 func BUG(f string, a ...interface{}) {
-	Log.LLog(slog.LBUG, 1, "BUG: sipsp: ", f, a...)
+	getLogger().LLog(slog.LBUG, 1, "BUG: sipsp: ", f, a...)
 }
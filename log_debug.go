@@ -20,10 +20,10 @@ func init() {
 
 // DBGon() is a shorthand for checking if generic debug logging is enabled
 func DBGon() bool {
-	return Log.DBGon()
+	return getLogger().L(slog.LDBG)
 }
 
 // DBG is a shorthand for logging a debug message.
 func DBG(f string, a ...interface{}) {
-	Log.LLog(slog.LDBG, 1, "DBG: sipsp:", f, a...)
+	getLogger().LLog(slog.LDBG, 1, "DBG: sipsp:", f, a...)
 }
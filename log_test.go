@@ -0,0 +1,88 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/intuitivelabs/slog"
+)
+
+// capLogger is a Logger that records all the messages.
+type capLogger struct {
+	lev  slog.LogLevel
+	msgs []string
+	levs []slog.LogLevel
+}
+
+func (l *capLogger) L(lev slog.LogLevel) bool {
+	return l.lev >= lev
+}
+
+func (l *capLogger) LLog(lev slog.LogLevel, callersSkip int, prefix string,
+	f string, a ...interface{}) {
+	if !l.L(lev) {
+		return
+	}
+	l.msgs = append(l.msgs, prefix+fmt.Sprintf(f, a...))
+	l.levs = append(l.levs, lev)
+}
+
+func TestSetLogger(t *testing.T) {
+	var l capLogger
+	l.lev = slog.LDBG
+	SetLogger(&l)
+	defer SetLogger(nil)
+
+	WARN("w %d\n", 1)
+	ERR("e %d\n", 2)
+	BUG("b %d\n", 3)
+	exp := []string{"WARNING: sipsp: w 1\n", "ERROR: sipsp: e 2\n",
+		"BUG: sipsp: b 3\n"}
+	expLev := []slog.LogLevel{slog.LWARN, slog.LERR, slog.LBUG}
+	if DBGon() {
+		DBG("d %d\n", 4)
+		exp = append(exp, "DBG: sipsp:d 4\n")
+		expLev = append(expLev, slog.LDBG)
+	}
+	if len(l.msgs) != len(exp) {
+		t.Fatalf("captured %d messages, expected %d: %q",
+			len(l.msgs), len(exp), l.msgs)
+	}
+	for i := range exp {
+		if l.msgs[i] != exp[i] || l.levs[i] != expLev[i] {
+			t.Errorf("message %d: %q level %d, expected %q level %d",
+				i, l.msgs[i], l.levs[i], exp[i], expLev[i])
+		}
+	}
+
+	// debug messages silenced by the logger
+	l.lev = slog.LERR
+	l.msgs = l.msgs[:0]
+	if DBGon() {
+		t.Errorf("DBGon() returned true with the logger level %d", l.lev)
+	}
+	DBG("d\n")
+	WARN("w\n")
+	if len(l.msgs) != 0 {
+		t.Errorf("unexpected messages: %q", l.msgs)
+	}
+
+	// restore the default
+	SetLogger(nil)
+	if getLogger() != Logger(&Log) {
+		t.Errorf("SetLogger(nil) did not restore the default logger")
+	}
+}
+
+func TestDefaultLoggerAllocs(t *testing.T) {
+	SetLogger(nil)
+	if n := testing.AllocsPerRun(100, func() { DBGon() }); n != 0 {
+		t.Errorf("DBGon() with the default logger: %v allocations", n)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+// DefaultLoopMaxVias is a suggested Via count threshold for LoopSuspect().
+const DefaultLoopMaxVias = 16
+
+// countViaVals returns the number of via values in a Via header body
+// (comma separated, commas inside quoted strings are ignored).
+func countViaVals(viab []byte) int {
+	n := 0
+	quoted := false
+	nonWS := false // non whitespace found in the current value
+	for i := 0; i < len(viab); i++ {
+		switch c := viab[i]; {
+		case quoted:
+			if c == '\\' {
+				i++ // skip escaped char
+			} else if c == '"' {
+				quoted = false
+			}
+		case c == '"':
+			quoted = true
+			nonWS = true
+		case c == ',':
+			if nonWS {
+				n++
+			}
+			nonWS = false
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			nonWS = true
+		}
+	}
+	if nonWS {
+		n++
+	}
+	return n
+}
+
+// ViaCount returns the number of Via values in the message (a Via header
+// can contain more comma separated values) and ErrHdrOk on success or
+// ErrHdrTrunc if not all the message headers could be inspected (the
+// message has more headers then len(m.HL.Hdrs)), in which case the
+// returned value is only a lower bound.
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) ViaCount() (int, ErrorHdr) {
+	n := 0
+	err := m.HL.GetAll(m.Buf, []byte("via"), func(h *Hdr) bool {
+		n += countViaVals(h.Val.Get(m.Buf))
+		return true
+	})
+	return n, err
+}
+
+// MaxForwards returns the Max-Forwards header value and true, or 0 and
+// false if the message has no (parsed) Max-Forwards header.
+func (m *PSIPMsg) MaxForwards() (uint32, bool) {
+	if !m.PV.MaxFwd.Parsed() {
+		return 0, false
+	}
+	return m.PV.MaxFwd.UIVal, true
+}
+
+// LoopSuspect returns true if the message could be part of a routing loop
+// (or a spiral): either it has a Max-Forwards value less or equal to
+// minMaxFwd (e.g. 0), or more then maxVias Via values
+// (e.g. DefaultLoopMaxVias). A 0 maxVias disables the Via count check.
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) LoopSuspect(minMaxFwd uint32, maxVias int) bool {
+	if mf, ok := m.MaxForwards(); ok && mf <= minMaxFwd {
+		return true
+	}
+	if maxVias > 0 {
+		n, _ := m.ViaCount()
+		return n > maxVias
+	}
+	return false
+}
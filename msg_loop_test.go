@@ -0,0 +1,67 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoopSuspect(t *testing.T) {
+	const msgStart = `OPTIONS sip:bob@biloxi.com SIP/2.0\r
+`
+	const msgEnd = `To: <sip:bob@biloxi.com>\r
+From: <sip:alice@atlanta.com>;tag=1928301774\r
+Call-ID: a84b4c76e66710@pc33.atlanta.com\r
+CSeq: 1 OPTIONS\r
+\r
+`
+	via := "Via: SIP/2.0/UDP h1.test;branch=z9hG4bKa1\r\n"
+	type testCase struct {
+		hdrs   string // extra headers
+		mf     int    // expected max-forwards, -1 for none
+		vias   int    // expected via count
+		minMF  uint32
+		maxVia int
+		loop   bool
+	}
+	tests := [...]testCase{
+		{"Max-Forwards: 70\r\n" + via, 70, 1, 0, DefaultLoopMaxVias, false},
+		{"Max-Forwards: 0\r\n" + via, 0, 1, 0, DefaultLoopMaxVias, true},
+		{"Max-Forwards: 2\r\n" + via, 2, 1, 2, DefaultLoopMaxVias, true},
+		{via, -1, 1, 0, DefaultLoopMaxVias, false},
+		{"Max-Forwards: 50\r\n" + strings.Repeat(via, 20), 50, 20,
+			0, DefaultLoopMaxVias, true},
+		{"Max-Forwards: 50\r\n" + strings.Repeat(via, 20), 50, 20,
+			0, 0, false},
+		{"Max-Forwards: 50\r\n" +
+			"v: SIP/2.0/UDP h1;branch=z9hG4bKa1, SIP/2.0/UDP h2;x=\"a,b\"," +
+			"\r\n SIP/2.0/TCP [2001:db8::1]:5060\r\n" + via,
+			50, 4, 0, 3, true},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(msgStart + tc.hdrs + msgEnd)
+		msg.Init(buf, make([]Hdr, 32), nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		mf, ok := msg.MaxForwards()
+		if ok != (tc.mf >= 0) || (ok && int(mf) != tc.mf) {
+			t.Errorf("MaxForwards(%q) = %d, %v expected %d",
+				buf, mf, ok, tc.mf)
+		}
+		if n, err := msg.ViaCount(); n != tc.vias || err != 0 {
+			t.Errorf("ViaCount(%q) = %d, %d (%q) expected %d",
+				buf, n, err, err, tc.vias)
+		}
+		if l := msg.LoopSuspect(tc.minMF, tc.maxVia); l != tc.loop {
+			t.Errorf("LoopSuspect(%q, %d, %d) = %v expected %v",
+				buf, tc.minMF, tc.maxVia, l, tc.loop)
+		}
+	}
+}
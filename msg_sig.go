@@ -230,7 +230,13 @@ func GetMsgSig(msg *PSIPMsg) (MsgSig, ErrorHdr) {
 	// hdr sigs + via sig
 	var seen HdrFlags
 	sig.HdrSigLen = 0
-	for _, h := range msg.HL.Hdrs {
+	n := msg.HL.N
+	if n > len(msg.HL.Hdrs) {
+		n = len(msg.HL.Hdrs)
+	}
+	// headers are inspected in wire order, a header line containing
+	// several comma separated values (e.g. Contact, Via) counts once
+	for _, h := range msg.HL.Hdrs[:n] {
 		// add to sig only the first hdr occurence
 		if !seen.Test(h.Type) {
 			seen.Set(h.Type)
@@ -252,7 +258,7 @@ func GetMsgSig(msg *PSIPMsg) (MsgSig, ErrorHdr) {
 					return sig, ErrHdrOk
 				}
 			}
-			if (msg.HL.PFlags & sigHdrsFlags) == seen {
+			if (msg.HL.PFlags & sigHdrsFlags) == (seen & sigHdrsFlags) {
 				// already seen all the interesting headers in the message
 				return sig, ErrHdrOk
 			}
@@ -0,0 +1,74 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"testing"
+)
+
+func TestGetMsgSigHdrOrder(t *testing.T) {
+	const (
+		via     = "Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r\n"
+		viaC    = "v: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r\n"
+		from    = "From: <sip:alice@atlanta.com>;tag=1928301774\r\n"
+		fromC   = "f: <sip:alice@atlanta.com>;tag=1928301774\r\n"
+		to      = "To: <sip:bob@biloxi.com>\r\n"
+		cid     = "Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n"
+		cseq    = "CSeq: 314159 INVITE\r\n"
+		ct2     = "Contact: <sip:a@1.2.3.4>, <sip:b@1.2.3.4>\r\n"
+		ct2C    = "m: <sip:a@1.2.3.4>, <sip:b@1.2.3.4>\r\n"
+		ct1     = "Contact: <sip:a@1.2.3.4>\r\nContact: <sip:b@1.2.3.4>\r\n"
+		other   = "X-Foo: bar\r\nContent-Type: application/sdp\r\n"
+		reqLine = "INVITE sip:bob@biloxi.com SIP/2.0\r\n"
+	)
+	sig := func(hdrs string) MsgSig {
+		var msg PSIPMsg
+		buf := []byte(reqLine + hdrs + "\r\n")
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		s, err := GetMsgSig(&msg)
+		if err != ErrHdrOk {
+			t.Fatalf("GetMsgSig(%q) failed: %d (%q)", buf, err, err)
+		}
+		return s
+	}
+	type testCase struct {
+		m1, m2 string
+		eq     bool
+	}
+	tests := [...]testCase{
+		{via + from + to + cid + cseq + ct2, via + from + to + cid + cseq + ct2,
+			true},
+		// different order
+		{via + from + to + cid + cseq, from + via + to + cid + cseq, false},
+		{via + from + to + cid + cseq + ct2, via + from + to + ct2 + cid + cseq,
+			false},
+		// compact vs long form
+		{via + from + to + cid + cseq, viaC + from + to + cid + cseq, false},
+		{via + from + to + cid + cseq, via + fromC + to + cid + cseq, false},
+		{via + from + to + cid + cseq + ct2, via + from + to + cid + cseq + ct2C,
+			false},
+		// comma folded contacts vs. one per line
+		{via + from + to + cid + cseq + ct2, via + from + to + cid + cseq + ct1,
+			true},
+		// headers not part of the sig
+		{via + from + to + cid + cseq, via + other + from + to + cid + cseq,
+			true},
+		// repeated headers: only the first one counts
+		{via + from + to + cid + cseq, via + from + to + via + cid + cseq,
+			true},
+	}
+	for _, tc := range tests {
+		s1, s2 := sig(tc.m1), sig(tc.m2)
+		if (s1 == s2) != tc.eq {
+			t.Errorf("GetMsgSig(%q) = %s, GetMsgSig(%q) = %s: equal %v"+
+				" expected %v", tc.m1, s1, tc.m2, s2, s1 == s2, tc.eq)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"bytes"
+
+	"github.com/intuitivelabs/bytescase"
+)
+
+// rfc3261 branch "magic cookie"
+var viaBrMagic = []byte("z9hG4bK")
+
+// skipViaWS jumps over white space, including CR and LF (folded lines).
+func skipViaWS(buf []byte, offs int) int {
+	for ; offs < len(buf) &&
+		(buf[offs] == ' ' || buf[offs] == '\t' ||
+			buf[offs] == '\r' || buf[offs] == '\n'); offs++ {
+		// empty
+	}
+	return offs
+}
+
+// viaSentByBranch returns the sent-by part (host[:port]) and the branch
+// parameter value of the first via in viab.
+// viab should contain the via body complete with parameters
+// (e.g. "SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds").
+// It returns false if no sent-by was found. If the via has no branch
+// parameter, the returned branch will be empty.
+func viaSentByBranch(viab []byte) (sentBy, branch PField, ok bool) {
+	// skip over sent-protocol: name / version / transport
+	o := 0
+	for slashes := 0; slashes < 2; o++ {
+		if o >= len(viab) || viab[o] == ',' || viab[o] == ';' {
+			return sentBy, branch, false
+		}
+		if viab[o] == '/' {
+			slashes++
+		}
+	}
+	o = skipViaWS(viab, o)
+	o = skipToken(viab, o) // transport
+	o = skipViaWS(viab, o)
+	s := o
+	for ; o < len(viab) && viab[o] != ';' && viab[o] != ',' &&
+		viab[o] != ' ' && viab[o] != '\t' &&
+		viab[o] != '\r' && viab[o] != '\n'; o++ {
+	}
+	if o == s {
+		return sentBy, branch, false
+	}
+	sentBy.Set(s, o)
+	o = skipViaWS(viab, o)
+	if o >= len(viab) || viab[o] != ';' {
+		return sentBy, branch, true // no params
+	}
+	o++ // skip over ';'
+
+	// ';' separated, ',' or end of string ended
+	const flags = POptParamSemiSepF | POptTokCommaTermF | POptInputEndF
+	var param PTokParam
+	for {
+		next, err := ParseTokenParam(viab, o, &param, flags)
+		if err != 0 && err != ErrHdrMoreValues && err != ErrHdrEOH {
+			break
+		}
+		if param.Name.Len == 6 &&
+			bytescase.CmpEq(param.Name.Get(viab), []byte("branch")) {
+			branch = param.Val
+			break
+		}
+		if err != ErrHdrMoreValues {
+			break
+		}
+		o = next
+		param.Reset()
+	}
+	return sentBy, branch, true
+}
+
+// topVia returns the first Via header body, the top Via sent-by and
+// branch (PFields pointing inside the returned via body) and true on
+// success, or false if no Via header was parsed or the sent-by could not
+// be found.
+func (m *PSIPMsg) topVia() (viab []byte, sentBy, branch PField, ok bool) {
+	if !m.HL.PFlags.Test(HdrVia) {
+		return nil, sentBy, branch, false
+	}
+	via := m.HL.GetHdr(HdrVia)
+	if via == nil || via.Missing() {
+		return nil, sentBy, branch, false
+	}
+	viab = via.Val.Get(m.Buf)
+	sentBy, branch, ok = viaSentByBranch(viab)
+	return viab, sentBy, branch, ok
+}
+
+// TransactionKey appends to dst[:0] a key identifying the transaction the
+// message belongs to and returns the result.
+// The key is built, according to RFC3261 17.2.3, from the top Via branch,
+// the top Via sent-by (lowercased, since it is case-insensitive) and the
+// CSeq method, with ACK using the INVITE method (so that an ACK for a
+// negative reply matches the INVITE transaction). CANCEL keeps its own
+// method and so it gets a different key from the INVITE it cancels, even
+// if the branch is the same.
+// Replies get the same key as the corresponding request.
+// It returns false if the key cannot be built: Via or CSeq missing or not
+// parsed, or the top Via branch does not start with the RFC3261 magic
+// cookie (RFC2543 transaction matching is not supported).
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) TransactionKey(dst []byte) ([]byte, bool) {
+	if !m.PV.CSeq.Parsed() {
+		return dst[:0], false
+	}
+	viab, sentBy, branch, ok := m.topVia()
+	if !ok || int(branch.Len) <= len(viaBrMagic) ||
+		!bytescase.CmpEq(branch.Get(viab)[:len(viaBrMagic)], viaBrMagic) {
+		return dst[:0], false
+	}
+	method := m.PV.CSeq.Method.Get(m.Buf)
+	if m.PV.CSeq.MethodNo == MAck {
+		method = MInvite.Name()
+	}
+	key := append(dst[:0], branch.Get(viab)...)
+	key = append(key, '|')
+	l := len(key)
+	key = append(key, sentBy.Get(viab)...)
+	bytescase.ToLower(sentBy.Get(viab), key[l:])
+	key = append(key, '|')
+	key = append(key, method...)
+	return key, true
+}
+
+// IsRetransmission returns true if the message m is a retransmission of
+// the message prev (both messages must be parsed, at least up to the end
+// of the headers).
+// Two messages are considered retransmissions if they have the same type
+// (request or reply), method, CSeq number, Call-ID, From and To tags, top
+// Via sent-by and branch and, for replies, the same status.
+// Note that an ACK for a 2xx reply or a CANCEL are not retransmissions of
+// the INVITE (different method) and that replies with different To tags
+// (e.g. forked 180s) are not retransmissions of each other.
+func IsRetransmission(m, prev *PSIPMsg) bool {
+	if m.Request() != prev.Request() ||
+		!m.PV.CSeq.Parsed() || !prev.PV.CSeq.Parsed() ||
+		m.PV.CSeq.CSeqNo != prev.PV.CSeq.CSeqNo ||
+		m.Method() != prev.Method() ||
+		!m.PV.CSeq.Method.Eq(m.Buf, prev.PV.CSeq.Method, prev.Buf) {
+		return false
+	}
+	if !m.Request() && m.FL.Status != prev.FL.Status {
+		return false
+	}
+	if !m.PV.Callid.CallID.Eq(m.Buf, prev.PV.Callid.CallID, prev.Buf) ||
+		!m.PV.From.Tag.Eq(m.Buf, prev.PV.From.Tag, prev.Buf) ||
+		!m.PV.To.Tag.Eq(m.Buf, prev.PV.To.Tag, prev.Buf) {
+		return false
+	}
+	viab1, sentBy1, branch1, ok1 := m.topVia()
+	viab2, sentBy2, branch2, ok2 := prev.topVia()
+	if ok1 != ok2 {
+		return false
+	}
+	return !ok1 || (branch1.Eq(viab1, branch2, viab2) &&
+		sentBy1.EqFold(viab1, sentBy2, viab2))
+}
+
+// DialogID appends to dst[:0] a string identifying the dialog the message
+// belongs to and returns the result.
+// The id has the form callid:tag1:tag2, where tag1 and tag2 are the From
+// and To tags in byte order, so that messages sent in both directions
+// (e.g. a BYE from the caller or from the callee) produce the same id.
+// It returns false if the Call-ID, the From tag or the To tag are missing
+// (e.g. an initial INVITE, before the dialog is established).
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) DialogID(dst []byte) ([]byte, bool) {
+	if !m.PV.Callid.Parsed() || !m.PV.From.Parsed() || !m.PV.To.Parsed() ||
+		m.PV.Callid.CallID.Empty() || m.PV.From.Tag.Empty() ||
+		m.PV.To.Tag.Empty() {
+		return dst[:0], false
+	}
+	t1 := m.PV.From.Tag.Get(m.Buf)
+	t2 := m.PV.To.Tag.Get(m.Buf)
+	if bytes.Compare(t1, t2) > 0 {
+		t1, t2 = t2, t1
+	}
+	id := append(dst[:0], m.PV.Callid.CallID.Get(m.Buf)...)
+	id = append(id, ':')
+	id = append(id, t1...)
+	id = append(id, ':')
+	id = append(id, t2...)
+	return id, true
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransactionKey(t *testing.T) {
+	type testCase struct {
+		m   string // message
+		key string // expected key, "" if no key
+	}
+	const inviteKey = "z9hG4bK776asdhds|pc33.atlanta.com|INVITE"
+	tests := [...]testCase{
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r
+CSeq: 314159 INVITE\r
+\r
+`, key: inviteKey},
+		// reply to the INVITE
+		{m: `SIP/2.0 486 Busy Here\r
+Via: SIP / 2.0 / UDP  pc33.atlanta.com ;received=1.2.3.4; branch=z9hG4bK776asdhds\r
+CSeq: 314159 INVITE\r
+\r
+`, key: inviteKey},
+		// sent-by is case-insensitive
+		{m: `SIP/2.0 486 Busy Here\r
+Via: SIP/2.0/UDP PC33.Atlanta.COM;branch=z9hG4bK776asdhds\r
+CSeq: 314159 INVITE\r
+\r
+`, key: inviteKey},
+		// ACK for the negative reply: same transaction
+		{m: `ACK sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r
+CSeq: 314159 ACK\r
+\r
+`, key: inviteKey},
+		// CANCEL: same branch, different transaction
+		{m: `CANCEL sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r
+CSeq: 314159 CANCEL\r
+\r
+`, key: "z9hG4bK776asdhds|pc33.atlanta.com|CANCEL"},
+		// multiple vias in the same header, top one used
+		{m: `BYE sip:bob@biloxi.com SIP/2.0\r
+v: SIP/2.0/TCP [2001:db8::1]:5060;branch=z9hG4bKa1, SIP/2.0/UDP h2;branch=z9hG4bKa2\r
+Via: SIP/2.0/UDP h3;branch=z9hG4bKa3\r
+CSeq: 2 BYE\r
+\r
+`, key: "z9hG4bKa1|[2001:db8::1]:5060|BYE"},
+		// rfc2543 branch
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=776asdhds\r
+CSeq: 314159 INVITE\r
+\r
+`, key: ""},
+		// no branch
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com\r
+CSeq: 314159 INVITE\r
+\r
+`, key: ""},
+		// no via
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+CSeq: 314159 INVITE\r
+\r
+`, key: ""},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		key, ok := msg.TransactionKey(make([]byte, 0, 10))
+		if ok != (tc.key != "") || string(key) != tc.key {
+			t.Errorf("TransactionKey(%q) = %q, %v expected %q",
+				buf, key, ok, tc.key)
+		}
+	}
+}
+
+func TestIsRetransmission(t *testing.T) {
+	const invite = `INVITE sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r
+From: <sip:alice@atlanta.com>;tag=1928301774\r
+To: <sip:bob@biloxi.com>\r
+Call-ID: a84b4c76e66710@pc33.atlanta.com\r
+CSeq: 314159 INVITE\r
+\r
+`
+	const reply = `SIP/2.0 180 Ringing\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r
+From: <sip:alice@atlanta.com>;tag=1928301774\r
+To: <sip:bob@biloxi.com>;tag=a6c85cf\r
+Call-ID: a84b4c76e66710@pc33.atlanta.com\r
+CSeq: 314159 INVITE\r
+\r
+`
+	type testCase struct {
+		m, prev string
+		retr    bool
+	}
+	tests := [...]testCase{
+		{invite, invite, true},
+		{reply, reply, true},
+		{invite, reply, false},
+		// new INVITE: new branch and CSeq
+		{strings.Replace(strings.Replace(invite, "776asdhds", "776asdhdt", 1),
+			"314159", "314160", -1), invite, false},
+		// same CSeq, new branch (not a retransmission)
+		{strings.Replace(invite, "776asdhds", "776asdhdt", 1), invite, false},
+		// different sent-by
+		{strings.Replace(invite, "pc33.atlanta.com;", "pc34.atlanta.com;", 1),
+			invite, false},
+		// different status
+		{strings.Replace(reply, "180 Ringing", "183 Progress", 1), reply,
+			false},
+		// different to-tag (fork)
+		{strings.Replace(reply, "a6c85cf", "a6c85cg", 1), reply, false},
+		// CANCEL with the same branch
+		{strings.Replace(invite, "INVITE", "CANCEL", -1), invite, false},
+	}
+	for _, tc := range tests {
+		var m, prev PSIPMsg
+		b1 := unescapeCRLF(tc.m)
+		b2 := unescapeCRLF(tc.prev)
+		m.Init(b1, nil, nil)
+		prev.Init(b2, nil, nil)
+		if _, err := ParseSIPMsg(b1, 0, &m, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", b1, err, err)
+		}
+		if _, err := ParseSIPMsg(b2, 0, &prev, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", b2, err, err)
+		}
+		if r := IsRetransmission(&m, &prev); r != tc.retr {
+			t.Errorf("IsRetransmission(%q, %q) = %v expected %v",
+				b1, b2, r, tc.retr)
+		}
+	}
+}
+
+func TestDialogID(t *testing.T) {
+	const bye = `BYE sip:bob@biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bKnashds8\r
+From: <sip:alice@atlanta.com>;tag=1928301774\r
+To: <sip:bob@biloxi.com>;tag=a6c85cf\r
+Call-ID: a84b4c76e66710@pc33.atlanta.com\r
+CSeq: 231 BYE\r
+\r
+`
+	const id = "a84b4c76e66710@pc33.atlanta.com:1928301774:a6c85cf"
+	type testCase struct {
+		m  string
+		id string // expected id, "" if none
+	}
+	tests := [...]testCase{
+		{bye, id},
+		// BYE sent by the callee: tags swapped
+		{strings.NewReplacer("tag=1928301774", "tag=a6c85cf",
+			"tag=a6c85cf", "tag=1928301774").Replace(bye), id},
+		// reply to the callee BYE
+		{strings.NewReplacer("BYE sip:bob@biloxi.com SIP/2.0",
+			"SIP/2.0 200 OK", "tag=1928301774", "tag=a6c85cf",
+			"tag=a6c85cf", "tag=1928301774").Replace(bye), id},
+		// no to-tag
+		{strings.Replace(bye, ";tag=a6c85cf", "", 1), ""},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		id, ok := msg.DialogID(nil)
+		if ok != (tc.id != "") || string(id) != tc.id {
+			t.Errorf("DialogID(%q) = %q, %v expected %q", buf, id, ok, tc.id)
+		}
+	}
+}
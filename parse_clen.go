@@ -61,10 +61,57 @@ const (
 // an error.
 // For more information see ParseUIntVal().
 func ParseCLenVal(buf []byte, offs int, pcl *PUIntBody) (int, ErrorHdr) {
+	return ParseUIntHeaderVal(buf, offs, pcl, clenOpts)
+}
+
+// PUIntOpts holds the options for ParseUIntHeaderVal().
+type PUIntOpts struct {
+	Max    uint32 // maximum value, 0 for no limit (other then 2^32-1)
+	MaxLen int    // maximum number of digits, 0 for no limit
+	// Clamp makes values bigger then Max (or 2^32-1) valid, they are
+	// clamped to Max (or 2^32-1) instead of returning ErrHdrNumTooBig.
+	// MaxLen is ignored if Clamp is set.
+	Clamp bool
+}
+
+var clenOpts = PUIntOpts{Max: MaxClenValue, MaxLen: MaxCLenValueSize}
+
+// ParseUIntHeaderVal parses the value of a header containing a single
+// unsigned integer, starting at offs in buf and filling pcl, applying the
+// limits in opts.
+// It returns a new offset pointing after the part that was parsed and
+// an error. Values exceeding opts.Max or opts.MaxLen result in
+// ErrHdrNumTooBig (with the returned offset pointing at the value start),
+// unless opts.Clamp is set.
+// For more information see ParseUIntVal().
+func ParseUIntHeaderVal(buf []byte, offs int, pcl *PUIntBody,
+	opts PUIntOpts) (int, ErrorHdr) {
 	o, err := ParseUIntVal(buf, offs, pcl)
-	if err == 0 &&
-		(pcl.SVal.Len > MaxCLenValueSize || pcl.UIVal > MaxClenValue) {
-		return int(pcl.SVal.Offs), ErrHdrNumTooBig
+	if !opts.Clamp {
+		if err == 0 &&
+			((opts.MaxLen > 0 && int(pcl.SVal.Len) > opts.MaxLen) ||
+				(opts.Max > 0 && pcl.UIVal > opts.Max)) {
+			return int(pcl.SVal.Offs), ErrHdrNumTooBig
+		}
+		return o, err
+	}
+	for err == ErrHdrNumTooBig && pcl.state == clFound {
+		// skip over the rest of the digits, the value is clamped below
+		for ; o < len(buf) && buf[o] >= '0' && buf[o] <= '9'; o++ {
+		}
+		if o >= len(buf) {
+			return o, ErrHdrMoreBytes
+		}
+		o, err = ParseUIntVal(buf, o, pcl)
+	}
+	if err == 0 {
+		if pcl.SVal.Len >= 10 {
+			// possible overflow, re-compute
+			pcl.UIVal, _ = pExpiresVal(pcl.SVal.Get(buf))
+		}
+		if opts.Max > 0 && pcl.UIVal > opts.Max {
+			pcl.UIVal = opts.Max
+		}
 	}
 	return o, err
 }
@@ -122,12 +169,12 @@ func ParseUIntVal(buf []byte, offs int, pcl *PUIntBody) (int, ErrorHdr) {
 				pcl.soffs = i
 				pcl.UIVal = uint32(c - '0')
 			case clFound:
-				v := pcl.UIVal*10 + uint32(c-'0')
-				if pcl.UIVal > v {
+				v := uint64(pcl.UIVal)*10 + uint64(c-'0')
+				if v > uint64(^uint32(0)) {
 					// overflow
 					return i, ErrHdrNumTooBig
 				}
-				pcl.UIVal = v
+				pcl.UIVal = uint32(v)
 			case clEnd:
 				// error, stuff found after callid end (WS in callid ?)
 				return i, ErrHdrBadChar
@@ -131,3 +131,117 @@ func testParseCLenExp(t *testing.T, buf []byte, offs int, eErr ErrorHdr,
 	}
 
 }
+
+// values overflowing an uint32 with a still valid looking wrapped around
+// result (e.g. 5000000000 -> 705032704) must be detected.
+func TestParseCLenValOverflow(t *testing.T) {
+	type testCase struct {
+		clen string
+		offs int // expected error offset (overflowing digit)
+	}
+	tests := [...]testCase{
+		{"4294967296", 9},
+		{"5000000000", 9},
+		{"00005000000000", 13},
+		{"99999999999", 9},
+	}
+	for _, tc := range tests {
+		buf := []byte(tc.clen + "\r\n\r\n")
+		var pcl PUIntBody
+		if o, err := ParseUIntVal(buf, 0, &pcl); err != ErrHdrNumTooBig ||
+			o != tc.offs {
+			t.Errorf("ParseUIntVal(%q) = %d, %d (%q) [val %d] expected"+
+				" %d, %d (%q)", buf, o, err, err, pcl.UIVal, tc.offs,
+				ErrHdrNumTooBig, ErrHdrNumTooBig)
+		}
+		pcl.Reset()
+		if o, err := ParseCLenVal(buf, 0, &pcl); err != ErrHdrNumTooBig ||
+			o != tc.offs {
+			t.Errorf("ParseCLenVal(%q) = %d, %d (%q) expected %d, %d (%q)",
+				buf, o, err, err, tc.offs, ErrHdrNumTooBig, ErrHdrNumTooBig)
+		}
+	}
+}
+
+func TestParseExpiresVal(t *testing.T) {
+	type testCase struct {
+		b   string
+		val uint32
+		err ErrorHdr
+	}
+	tests := [...]testCase{
+		{"3600\r\n\r\n", 3600, 0},
+		{" 4294967295 \r\n\r\n", 4294967295, 0},
+		{"4294967296\r\n\r\n", 4294967295, 0},
+		{"99999999999999999999999\r\n\r\n", 4294967295, 0},
+		{"abc\r\n\r\n", 0, ErrHdrBadChar},
+		{"\r\n\r\n", 0, ErrHdrBad},
+	}
+	for _, tc := range tests {
+		buf := []byte(tc.b)
+		// try also in pieces, 1 byte at a time
+		for _, step := range [...]int{len(buf), 1} {
+			var pcl PUIntBody
+			var o int
+			var err ErrorHdr = ErrHdrMoreBytes
+			for end := step; err == ErrHdrMoreBytes && end <= len(buf); end += step {
+				o, err = ParseExpiresVal(buf[:end], o, &pcl)
+			}
+			if err != tc.err {
+				t.Errorf("ParseExpiresVal(%q, step %d) = %d (%q) expected"+
+					" %d (%q)", buf, step, err, err, tc.err, tc.err)
+				continue
+			}
+			if err == 0 && pcl.UIVal != tc.val {
+				t.Errorf("ParseExpiresVal(%q, step %d) = %d expected %d",
+					buf, step, pcl.UIVal, tc.val)
+			}
+		}
+	}
+}
+
+func TestParseUIntHeaderVal(t *testing.T) {
+	type testCase struct {
+		b    string
+		opts PUIntOpts
+		val  uint32
+		err  ErrorHdr
+	}
+	tests := [...]testCase{
+		{"70\r\n\r\n", PUIntOpts{}, 70, 0},
+		{" \t 70 \t \r\n\r\n", PUIntOpts{}, 70, 0},
+		{"70\r\n 1\r\n\r\n", PUIntOpts{}, 0, ErrHdrBadChar},
+		{"7a\r\n\r\n", PUIntOpts{}, 0, ErrHdrBadChar},
+		{" \r\n\r\n", PUIntOpts{}, 0, ErrHdrBad},
+		{"4294967295\r\n\r\n", PUIntOpts{}, 4294967295, 0},
+		{"4294967296\r\n\r\n", PUIntOpts{}, 0, ErrHdrNumTooBig},
+		{"256\r\n\r\n", PUIntOpts{Max: 255}, 0, ErrHdrNumTooBig},
+		{"0255\r\n\r\n", PUIntOpts{Max: 255, MaxLen: 3}, 0, ErrHdrNumTooBig},
+		{" 256 \r\n\r\n", PUIntOpts{Max: 255, Clamp: true}, 255, 0},
+		{"4294967296 \r\n\r\n", PUIntOpts{Clamp: true}, 4294967295, 0},
+		{"99999999999999999999\r\n\r\n", PUIntOpts{Max: 1000, Clamp: true},
+			1000, 0},
+	}
+	for _, tc := range tests {
+		buf := []byte(tc.b)
+		// try also in pieces, 1 byte at a time
+		for _, step := range [...]int{len(buf), 1} {
+			var pcl PUIntBody
+			var o int
+			var err ErrorHdr = ErrHdrMoreBytes
+			for end := step; err == ErrHdrMoreBytes && end <= len(buf); end += step {
+				o, err = ParseUIntHeaderVal(buf[:end], o, &pcl, tc.opts)
+			}
+			if err != tc.err {
+				t.Errorf("ParseUIntHeaderVal(%q, %+v, step %d) = %d (%q)"+
+					" expected %d (%q)", buf, tc.opts, step, err, err,
+					tc.err, tc.err)
+				continue
+			}
+			if err == 0 && pcl.UIVal != tc.val {
+				t.Errorf("ParseUIntHeaderVal(%q, %+v, step %d) = %d"+
+					" expected %d", buf, tc.opts, step, pcl.UIVal, tc.val)
+			}
+		}
+	}
+}
@@ -10,18 +10,30 @@ import (
 //	"fmt"
 )
 
+// ContactsGrowF is the type for the function used to grow the space for
+// the parsed contacts (see PContacts.Grow).
+// It is called with the current values slice when it is full and it should
+// return a bigger slice, containing the old values (e.g. using append()).
+// If it returns a slice that is not bigger, the new contacts will be
+// dropped.
+type ContactsGrowF func(vals []PFromBody) []PFromBody
+
 // PContacts contains the parsed Contact header values for one or more
 // different Contact headers (all the contacts in the message that fit
 // in the parsed value array).
+// N is the total number of contacts found in the message, while only
+// VNo() (min(N, len(Vals))) of them are available in Vals. The rest are
+// dropped (see Dropped()), unless a Grow function is set.
 type PContacts struct {
 	Vals       []PFromBody // parsed contacts min(N, len(Vals))
 	N          int         // no of contact _values_ found, can be >len(Vals)
 	HNo        int         // no of different Contact: _headers_ found
 	MaxExpires uint32
 	MinExpires uint32
-	LastHVal   PField    // value part of the last contact _header_ parsed
-	last       PFromBody // used if no space in Vals, for keeping state
-	first      PFromBody // even if Vals is nil, we remember the first val.
+	LastHVal   PField        // value part of the last contact _header_ parsed
+	Grow       ContactsGrowF // optional, called when Vals is full
+	last       PFromBody     // used if no space in Vals, for keeping state
+	first      PFromBody     // even if Vals is nil, we remember the first val.
 }
 
 // VNo returns the number of parsed contacts headers.
@@ -54,14 +66,20 @@ func (c *PContacts) More() bool {
 	return c.N > len(c.Vals)
 }
 
+// Dropped returns the number of parsed contacts that did not fit in Vals.
+func (c *PContacts) Dropped() int {
+	return c.N - c.VNo()
+}
+
 // Reset re-initializes the parsed values.
 func (c *PContacts) Reset() {
 	for i := 0; i < c.VNo(); i++ {
 		c.Vals[i].Reset()
 	}
-	v := c.Vals
+	v, g := c.Vals, c.Grow
 	*c = PContacts{}
 	c.Vals = v
+	c.Grow = g
 }
 
 // Init initializes the contact values from an array of parsed values.
@@ -79,6 +97,28 @@ func (c *PContacts) Parsed() bool {
 	return c.N > 0
 }
 
+// Each calls f for each parsed contact value that is still available
+// (see GetContact()), in order, with the contact index, the contact URI,
+// the contact "expires" parameter value and q*1000 (0 if no q parameter
+// is present). hasExp is false if the contact has no valid "expires"
+// parameter, in which case the Expires header or the default must be used
+// by the caller (see PHdrVals.EffectiveExpires()).
+// For a "*" contact uri will be empty.
+// The iteration stops if f returns false.
+func (c *PContacts) Each(f func(idx int, uri PField, expires uint32,
+	hasExp bool, q uint16) bool) {
+	for i := 0; i < c.N; i++ {
+		cv := c.GetContact(i)
+		if cv == nil {
+			continue // not available (no space in Vals)
+		}
+		exp, _, valid := cv.ExpiresVal()
+		if !f(i, cv.URI, exp, valid, cv.Q) {
+			break
+		}
+	}
+}
+
 // ParseOneContact parses the content of one Contact vale, found at
 // offset offs in buf. pfrom will be filled with the parsed content.
 // See ParseNameAddrPVal() for more information.
@@ -101,6 +141,12 @@ func ParseAllContactValues(buf []byte, offs int, c *PContacts) (int, ErrorHdr) {
 		}
 	}
 	for {
+		if c.N == len(c.Vals) && c.Grow != nil && c.last.Empty() {
+			// no more space, try to grow (only when starting a new value)
+			if v := c.Grow(c.Vals); len(v) > len(c.Vals) {
+				c.Vals = v
+			}
+		}
 		if c.N < len(c.Vals) {
 			pf = &c.Vals[c.N]
 		} else {
@@ -147,3 +193,24 @@ func ParseAllContactValues(buf []byte, offs int, c *PContacts) (int, ErrorHdr) {
 	}
 	return next, err
 }
+
+// ContactBindingEq returns true if the contacts c1 and c2 identify the same
+// registration binding.
+// If both contacts have a +sip.instance parameter (RFC5626 outbound), the
+// binding is identified by the instance and reg-id values (so a contact
+// that changed its uri, e.g. due to a NAT rebinding, will still match).
+// Otherwise the contact uris are compared using URICmp() and the passed
+// flags. buf1 and buf2 are the buffers c1 and c2 point into.
+func ContactBindingEq(c1 *PFromBody, buf1 []byte, c2 *PFromBody, buf2 []byte,
+	flags URICmpFlags) bool {
+	if !c1.Instance.Empty() && !c2.Instance.Empty() {
+		return c1.RegID == c2.RegID &&
+			c1.Instance.Eq(buf1, c2.Instance, buf2)
+	}
+	if !c1.Instance.Empty() || !c2.Instance.Empty() {
+		// only one has an instance => different bindings
+		return false
+	}
+	res, _, _ := URIRawCmp(c1.URI.Get(buf1), c2.URI.Get(buf2), flags)
+	return res
+}
@@ -266,3 +266,177 @@ func TestParseAllContactsValues(t *testing.T) {
 		}
 	}
 }
+
+func TestContactBindingEq(t *testing.T) {
+	const inst = `;+sip.instance="<urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF>"`
+	type testCase struct {
+		c1, c2 string // contact values
+		eq     bool
+	}
+	tests := [...]testCase{
+		// same instance & reg-id, different uri (NAT rebinding)
+		{"<sip:bob@10.0.0.1:5060;transport=udp>" + inst + ";reg-id=1",
+			"<sip:bob@192.0.2.1:40123;transport=tcp>;reg-id=1" + inst,
+			true},
+		// different reg-id (different flow)
+		{"<sip:bob@10.0.0.1>" + inst + ";reg-id=1",
+			"<sip:bob@10.0.0.1>" + inst + ";reg-id=2", false},
+		// only one with instance
+		{"<sip:bob@10.0.0.1>" + inst, "<sip:bob@10.0.0.1>", false},
+		// no instance => uri comparison
+		{"<sip:bob@10.0.0.1>;expires=60", "sip:bob@10.0.0.1", true},
+		{"<sip:bob@10.0.0.1>", "<sip:bob@10.0.0.2>", false},
+	}
+	for _, tc := range tests {
+		var c1, c2 PFromBody
+		b1 := []byte(tc.c1 + "\r\n\r\n")
+		b2 := []byte(tc.c2 + "\r\n\r\n")
+		if _, err := ParseOneContact(b1, 0, &c1); err != 0 {
+			t.Fatalf("ParseOneContact(%q) failed: %d (%q)", b1, err, err)
+		}
+		if _, err := ParseOneContact(b2, 0, &c2); err != 0 {
+			t.Fatalf("ParseOneContact(%q) failed: %d (%q)", b2, err, err)
+		}
+		if r := ContactBindingEq(&c1, b1, &c2, b2, URICmpDefault); r != tc.eq {
+			t.Errorf("ContactBindingEq(%q, %q) = %v expected %v",
+				tc.c1, tc.c2, r, tc.eq)
+		}
+	}
+	// parsed values
+	var c PFromBody
+	b := []byte("<sip:bob@10.0.0.1>" + inst + ";reg-id=12\r\n\r\n")
+	if _, err := ParseOneContact(b, 0, &c); err != 0 ||
+		string(c.Instance.Get(b)) !=
+			"<urn:uuid:00000000-0000-1000-8000-AABBCCDDEEFF>" ||
+		c.RegID != 12 {
+		t.Errorf("ParseOneContact(%q) = %d (%q): instance %q reg-id %d",
+			b, err, err, c.Instance.Get(b), c.RegID)
+	}
+}
+
+func TestPContactsOverflow(t *testing.T) {
+	b := []byte("<sip:1@a.b>, <sip:2@a.b>;expires=5,\r\n <sip:3@a.b>\r\nX")
+	var c PContacts
+
+	// overflow
+	c.Init(make([]PFromBody, 1))
+	if _, err := ParseAllContactValues(b, 0, &c); err != 0 {
+		t.Fatalf("ParseAllContactValues(%q) failed: %d (%q)", b, err, err)
+	}
+	if c.N != 3 || c.VNo() != 1 || c.Dropped() != 2 || !c.More() {
+		t.Errorf("overflow: N %d VNo %d Dropped %d, expected 3, 1, 2",
+			c.N, c.VNo(), c.Dropped())
+	}
+	// re-parse with a bigger buffer
+	c.Init(make([]PFromBody, 4))
+	c.Reset()
+	if _, err := ParseAllContactValues(b, 0, &c); err != 0 {
+		t.Fatalf("ParseAllContactValues(%q) failed: %d (%q)", b, err, err)
+	}
+	if c.N != 3 || c.VNo() != 3 || c.Dropped() != 0 || c.More() {
+		t.Errorf("re-parse: N %d VNo %d Dropped %d, expected 3, 3, 0",
+			c.N, c.VNo(), c.Dropped())
+	}
+	// grow
+	growCalls := 0
+	c.Init(nil)
+	c.Grow = func(v []PFromBody) []PFromBody {
+		growCalls++
+		return append(v, PFromBody{})
+	}
+	c.Reset()
+	if _, err := ParseAllContactValues(b, 0, &c); err != 0 {
+		t.Fatalf("ParseAllContactValues(%q) failed: %d (%q)", b, err, err)
+	}
+	if c.N != 3 || c.VNo() != 3 || c.Dropped() != 0 || growCalls != 3 {
+		t.Errorf("grow: N %d VNo %d Dropped %d grow calls %d,"+
+			" expected 3, 3, 0, 3", c.N, c.VNo(), c.Dropped(), growCalls)
+	}
+	for i, u := range []string{"sip:1@a.b", "sip:2@a.b", "sip:3@a.b"} {
+		if string(c.Vals[i].URI.Get(b)) != u {
+			t.Errorf("grow: contact %d: %q expected %q",
+				i, c.Vals[i].URI.Get(b), u)
+		}
+	}
+	if c.Vals[1].Expires != 5 {
+		t.Errorf("grow: contact 1 expires %d, expected 5", c.Vals[1].Expires)
+	}
+}
+
+func TestContactExpiresVal(t *testing.T) {
+	type testCase struct {
+		c       string
+		val     uint32
+		present bool
+		valid   bool
+	}
+	tests := [...]testCase{
+		{"<sip:a@b.c>;expires=60", 60, true, true},
+		{"<sip:a@b.c>", 0, false, false},
+		{"<sip:a@b.c>;expires=abc", 0, true, false},
+		{"<sip:a@b.c>;expires=", 0, true, false},
+		{"<sip:a@b.c>;expires=4294967295", 4294967295, true, true},
+		{"<sip:a@b.c>;expires=4294967296", 4294967295, true, true},
+		{"<sip:a@b.c>;expires=99999999999999999999999", 4294967295,
+			true, true},
+	}
+	for _, tc := range tests {
+		var pf PFromBody
+		b := []byte(tc.c + "\r\nX")
+		if _, err := ParseOneContact(b, 0, &pf); err != 0 {
+			t.Fatalf("ParseOneContact(%q) failed: %d (%q)", b, err, err)
+		}
+		val, present, valid := pf.ExpiresVal()
+		if val != tc.val || present != tc.present || valid != tc.valid {
+			t.Errorf("ExpiresVal(%q) = %d, %v, %v expected %d, %v, %v",
+				tc.c, val, present, valid, tc.val, tc.present, tc.valid)
+		}
+	}
+}
+
+func TestPContactsEach(t *testing.T) {
+	type contact struct {
+		uri     string
+		expires uint32
+		hasExp  bool
+		q       uint16
+	}
+	b := []byte("<sip:1@a.b>;expires=60;q=0.5, sip:2@a.b;expires=0,\r\n" +
+		" <sip:3@a.b>;q=1;expires=bad\r\nX")
+	exp := []contact{
+		{"sip:1@a.b", 60, true, 500},
+		{"sip:2@a.b", 0, true, 0},
+		{"sip:3@a.b", 0, false, 1000},
+	}
+	var c PContacts
+	c.Init(make([]PFromBody, 4))
+	if _, err := ParseAllContactValues(b, 0, &c); err != 0 {
+		t.Fatalf("ParseAllContactValues(%q) failed: %d (%q)", b, err, err)
+	}
+	var res []contact
+	c.Each(func(idx int, uri PField, expires uint32, hasExp bool,
+		q uint16) bool {
+		if idx != len(res) {
+			t.Errorf("Each: unexpected index %d, expected %d", idx, len(res))
+		}
+		res = append(res, contact{string(uri.Get(b)), expires, hasExp, q})
+		return true
+	})
+	if len(res) != len(exp) {
+		t.Fatalf("Each: %d contacts, expected %d: %v", len(res), len(exp), res)
+	}
+	for i := range exp {
+		if res[i] != exp[i] {
+			t.Errorf("Each: contact %d: %v, expected %v", i, res[i], exp[i])
+		}
+	}
+	// stop early
+	n := 0
+	c.Each(func(int, PField, uint32, bool, uint16) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Each: callback called %d times after false, expected 1", n)
+	}
+}
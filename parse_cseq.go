@@ -60,6 +60,36 @@ const (
 	csFIN
 )
 
+// Number returns the numeric value of the CSeq.
+func (cs *PCSeqBody) Number() uint32 {
+	return cs.CSeqNo
+}
+
+// MethodName returns the raw method token (as it appears in buf).
+func (cs *PCSeqBody) MethodName(buf []byte) []byte {
+	return cs.Method.Get(buf)
+}
+
+// MethodType returns the method as SIPMethod (MOther for an unknown
+// method).
+func (cs *PCSeqBody) MethodType() SIPMethod {
+	return cs.MethodNo
+}
+
+// isTokenChar returns true if c is a valid rfc3261 token char
+// (alphanum / "-" / "." / "!" / "%" / "*" / "_" / "+" / "`" / "'" / "~").
+func isTokenChar(c byte) bool {
+	if (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') ||
+		(c >= 'a' && c <= 'z') {
+		return true
+	}
+	switch c {
+	case '-', '.', '!', '%', '*', '_', '+', '`', '\'', '~':
+		return true
+	}
+	return false
+}
+
 // ParseCSeqVal parses the value/content of a CSeq header.
 // The parameters are: a message buffer, the offset in the buffer where the
 // from: (or to:) value starts (should point after the ':') and a pointer
@@ -70,6 +100,9 @@ const (
 //  ErrHdrMoreBytes will be returned and this function can be called again
 // when more bytes are available, with the same buffer, the returned
 // offset ("continue point") and the same pfrom structure.
+// A CSeq with a non-numeric (or too big) number or a method containing
+// non-token chars will be rejected with ErrHdrBadChar (ErrHdrNumTooBig)
+// and a CSeq without a method with ErrHdrBad.
 func ParseCSeqVal(buf []byte, offs int, pcs *PCSeqBody) (int, ErrorHdr) {
 
 	if pcs.state == csFIN {
@@ -118,12 +151,12 @@ func ParseCSeqVal(buf []byte, offs int, pcs *PCSeqBody) (int, ErrorHdr) {
 				pcs.soffs = i
 				pcs.CSeqNo = uint32(c - '0')
 			case csFoundDigit:
-				v := pcs.CSeqNo*10 + uint32(c-'0')
-				if pcs.CSeqNo > v {
+				v := uint64(pcs.CSeqNo)*10 + uint64(c-'0')
+				if v > MaxCSeqNValue {
 					// overflow
 					return i, ErrHdrNumTooBig
 				}
-				pcs.CSeqNo = v
+				pcs.CSeqNo = uint32(v)
 			case csEndDigit:
 				pcs.state = csFoundMethod // method starting with a number(!)
 				pcs.soffs = i
@@ -134,7 +167,10 @@ func ParseCSeqVal(buf []byte, offs int, pcs *PCSeqBody) (int, ErrorHdr) {
 				return i, ErrHdrBadChar
 			}
 		default:
-			// non-number, could be method (we accept anything)
+			// non-number, could be method (only token chars allowed)
+			if !isTokenChar(c) {
+				return i, ErrHdrBadChar
+			}
 			switch pcs.state {
 			case csInit, csFoundDigit:
 				// non-number when number expected => error
@@ -180,3 +216,19 @@ endOfHdr:
 	pcs.MethodNo = GetMethodNo(pcs.Method.Get(buf))
 	return n + crl, 0
 }
+
+// CSeqCmp compares 2 CSeq numbers, taking into account a possible
+// wrap-around (serial number arithmetic, similar to RFC1982).
+// It returns 0 if a == b, a negative value if a is "before" b and a
+// positive value if a is "after" b.
+// A difference bigger then 2^31 is considered a wrap-around, e.g.
+// CSeqCmp(1, 0xffffffff) > 0 (1 comes after 2^32-1).
+func CSeqCmp(a, b uint32) int {
+	d := int32(a - b)
+	if d < 0 {
+		return -1
+	} else if d > 0 {
+		return 1
+	}
+	return 0
+}
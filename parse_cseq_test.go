@@ -180,3 +180,75 @@ func testParseCSeqExp(t *testing.T, buf []byte, offs int, exp expCSeqRes) {
 	}
 
 }
+
+func TestCSeqCmp(t *testing.T) {
+	type testCase struct {
+		a, b uint32
+		res  int
+	}
+	tests := [...]testCase{
+		{1, 1, 0},
+		{1, 2, -1},
+		{2, 1, 1},
+		{0, MaxCSeqNValue, 1},             // wrap
+		{MaxCSeqNValue, 0, -1},            // wrap
+		{5, MaxCSeqNValue - 5, 1},         // wrap
+		{MaxCSeqNValue - 5, 5, -1},        // wrap
+		{0x7fffffff, 0, 1},                // biggest non-wrap difference
+		{0, 0x7fffffff, -1},               // biggest non-wrap difference
+		{0x80000001, 0, -1},               // wrap
+		{MaxCSeqNValue, MaxCSeqNValue, 0}, // equal
+	}
+	for _, tc := range tests {
+		if r := CSeqCmp(tc.a, tc.b); r != tc.res {
+			t.Errorf("CSeqCmp(%d, %d) = %d, expected %d",
+				tc.a, tc.b, r, tc.res)
+		}
+	}
+}
+
+func TestParseCSeqValBad(t *testing.T) {
+	type testCase struct {
+		b   string
+		err ErrorHdr
+	}
+	tests := [...]testCase{
+		{"abc INVITE\r\n\r\n", ErrHdrBadChar},
+		{"1a INVITE\r\n\r\n", ErrHdrBadChar},
+		{"1\r\n\r\n", ErrHdrBad},
+		{"1 \r\n\r\n", ErrHdrBad},
+		{"\r\n\r\n", ErrHdrBad},
+		{"1 INV@TE\r\n\r\n", ErrHdrBadChar},
+		{"1 INVITE foo\r\n\r\n", ErrHdrBadChar},
+		{"4294967296 INVITE\r\n\r\n", ErrHdrNumTooBig},
+		{"9999999999 INVITE\r\n\r\n", ErrHdrNumTooBig},
+		{"4294967295 INVITE\r\n\r\n", 0},
+		{"1 X-Foo.bar!~\r\n\r\n", 0},
+	}
+	for _, tc := range tests {
+		var pcs PCSeqBody
+		_, err := ParseCSeqVal([]byte(tc.b), 0, &pcs)
+		if err != tc.err {
+			t.Errorf("ParseCSeqVal(%q) = %d (%q) expected %d (%q)",
+				tc.b, err, err, tc.err, tc.err)
+		}
+	}
+}
+
+func TestPCSeqBodyAccessors(t *testing.T) {
+	var pcs PCSeqBody
+	buf := []byte("314159 INVITE\r\n\r\n")
+	if _, err := ParseCSeqVal(buf, 0, &pcs); err != 0 {
+		t.Fatalf("ParseCSeqVal(%q) failed: %d (%q)", buf, err, err)
+	}
+	if pcs.Number() != 314159 {
+		t.Errorf("Number() = %d expected 314159", pcs.Number())
+	}
+	if string(pcs.MethodName(buf)) != "INVITE" {
+		t.Errorf("MethodName() = %q expected \"INVITE\"", pcs.MethodName(buf))
+	}
+	if pcs.MethodType() != MInvite {
+		t.Errorf("MethodType() = %s expected %s",
+			pcs.MethodType().Name(), MInvite.Name())
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"github.com/intuitivelabs/bytescase"
+)
+
+// MaxCustomHdrs is the maximum number of custom headers that can be
+// registered (see RegisterHeader()).
+const MaxCustomHdrs = 8
+
+// HdrParserF is the type for custom header value parsers.
+// The parser is called with the complete and trimmed header value, found
+// in buf[offs:] (buf ends at the end of the value). It should return the
+// parsed part of the value (as offsets in buf) and an error (0 on success).
+type HdrParserF func(buf []byte, offs int) (PField, ErrorHdr)
+
+// customHdr holds a registered custom header.
+type customHdr struct {
+	name   []byte // lowercase header name
+	parser HdrParserF
+}
+
+var customHdrs []customHdr
+
+// RegisterHeader registers a custom header parser for header name.
+// After registration, each time a header with the given name is found
+// (case-insensitive match), parser will be called for its value and the
+// result will be saved in PHdrVals.Custom (see PCustomHdrs.Get()).
+// Only non-standard headers (for which GetHdrType() returns HdrOther) can
+// be registered. Registering an already registered header will replace
+// its parser.
+// It returns ErrHdrOk on success, ErrHdrEmpty for an empty name or nil
+// parser, ErrHdrBad for an already known header and ErrHdrTrunc if more
+// then MaxCustomHdrs headers would be registered.
+// RegisterHeader is not concurrency safe and should be called only at
+// init time, before parsing any message.
+func RegisterHeader(name []byte, parser HdrParserF) ErrorHdr {
+	if len(name) == 0 || parser == nil {
+		return ErrHdrEmpty
+	}
+	if GetHdrType(name) != HdrOther {
+		return ErrHdrBad
+	}
+	if i := customHdrIdx(name); i >= 0 {
+		customHdrs[i].parser = parser
+		return ErrHdrOk
+	}
+	if len(customHdrs) >= MaxCustomHdrs {
+		return ErrHdrTrunc
+	}
+	n := make([]byte, len(name))
+	if err := bytescase.ToLower(name, n); err != nil {
+		return ErrHdrBad
+	}
+	customHdrs = append(customHdrs, customHdr{name: n, parser: parser})
+	return ErrHdrOk
+}
+
+// resetCustomHdrs removes all the registered custom headers.
+// Like RegisterHeader(), it is not concurrency safe.
+func resetCustomHdrs() {
+	customHdrs = nil
+}
+
+// customHdrIdx returns the registration index of the custom header with
+// the given name or -1 if no such header was registered.
+func customHdrIdx(name []byte) int {
+	for i := range customHdrs {
+		if len(name) == len(customHdrs[i].name) &&
+			bytescase.CmpEq(name, customHdrs[i].name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// PCustomHdr holds the parsed value of a registered custom header.
+type PCustomHdr struct {
+	Name PField   // header name (first header found)
+	Val  PField   // value returned by the custom parser (first header)
+	Err  ErrorHdr // error returned by the custom parser (first header)
+	N    int      // number of headers found
+}
+
+// Parsed returns true if the custom header was found and parsed.
+func (ch *PCustomHdr) Parsed() bool {
+	return ch.N > 0
+}
+
+// PCustomHdrs holds the parsed values for all the registered custom
+// headers.
+type PCustomHdrs struct {
+	Vals [MaxCustomHdrs]PCustomHdr // indexed by registration order
+}
+
+// Reset re-initializes the parsed values.
+func (c *PCustomHdrs) Reset() {
+	*c = PCustomHdrs{}
+}
+
+// Get returns the parsed value for the custom header name or nil if
+// the header was not registered or not found in the message.
+func (c *PCustomHdrs) Get(name []byte) *PCustomHdr {
+	if i := customHdrIdx(name); i >= 0 && c.Vals[i].Parsed() {
+		return &c.Vals[i]
+	}
+	return nil
+}
+
+// parseCustomHdr calls the registered custom parser (if any) for the
+// already parsed generic header h and saves the result in c.
+// Only the value of the first header with a given name is saved.
+// The custom parser errors are saved in the parsed value and they do not
+// affect the header parsing.
+func parseCustomHdr(buf []byte, h *Hdr, c *PCustomHdrs) {
+	i := customHdrIdx(h.Name.Get(buf))
+	if i < 0 {
+		return
+	}
+	ch := &c.Vals[i]
+	ch.N++
+	if ch.N > 1 {
+		return
+	}
+	ch.Name = h.Name
+	end := int(h.Val.Offs + h.Val.Len)
+	ch.Val, ch.Err = customHdrs[i].parser(buf[:end], int(h.Val.Offs))
+}
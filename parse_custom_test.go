@@ -0,0 +1,82 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"testing"
+)
+
+// parses "id;params" and returns the id
+func testXCidParser(buf []byte, offs int) (PField, ErrorHdr) {
+	var v PField
+	i := offs
+	for ; i < len(buf) && buf[i] != ';'; i++ {
+	}
+	if i == offs {
+		return v, ErrHdrEmpty
+	}
+	v.Set(offs, i)
+	return v, ErrHdrOk
+}
+
+func TestRegisterHeader(t *testing.T) {
+	// don't leak the registration into other tests
+	defer resetCustomHdrs()
+	if err := RegisterHeader([]byte("X-Cid"), testXCidParser); err != 0 {
+		t.Fatalf("RegisterHeader failed: %d (%q)", err, err)
+	}
+	if err := RegisterHeader([]byte("Call-ID"), testXCidParser); err != ErrHdrBad {
+		t.Errorf("RegisterHeader(Call-ID) = %d (%q) expected %q",
+			err, err, ErrHdrBad)
+	}
+	if err := RegisterHeader(nil, testXCidParser); err != ErrHdrEmpty {
+		t.Errorf("RegisterHeader(nil) = %d (%q) expected %q",
+			err, err, ErrHdrEmpty)
+	}
+
+	type testCase struct {
+		m   string
+		val string // expected value, "" for not found
+		err ErrorHdr
+		n   int
+	}
+	tests := [...]testCase{
+		{"INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+			"x-cid:  abc123;foo=bar \r\nCSeq: 1 INVITE\r\n" +
+			"X-CID: second\r\n\r\n", "abc123", 0, 2},
+		{"INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+			"X-Cid: ;foo\r\nCSeq: 1 INVITE\r\n\r\n", "", ErrHdrEmpty, 1},
+		{"INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+			"X-Other: abc\r\nCSeq: 1 INVITE\r\n\r\n", "", 0, 0},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		ch := msg.PV.Custom.Get([]byte("x-cid"))
+		if tc.n == 0 {
+			if ch != nil {
+				t.Errorf("%q: unexpected custom header value %q",
+					buf, ch.Val.Get(msg.Buf))
+			}
+			continue
+		}
+		if ch == nil {
+			t.Errorf("%q: custom header not found", buf)
+			continue
+		}
+		if string(ch.Val.Get(msg.Buf)) != tc.val || ch.Err != tc.err ||
+			ch.N != tc.n {
+			t.Errorf("%q: custom header %q, %d (%q), %d expected"+
+				" %q, %d (%q), %d", buf, ch.Val.Get(msg.Buf), ch.Err, ch.Err,
+				ch.N, tc.val, tc.err, tc.err, tc.n)
+		}
+	}
+}
@@ -34,6 +34,16 @@ const (
 	ErrHdrBug
 	ErrConvBug
 	ErrHdrTooManyVals
+	ErrHdrKeepAlive // only whitespace (CRLF) found, no message
+	ErrHdrBadCLen   // Content-Length does not match the body
+	ErrHdrNoCallID  // no Call-ID header (or empty)
+	ErrHdrNoCSeq    // no CSeq header
+	ErrHdrBadCSeq   // CSeq method does not match the request method
+	ErrHdrNoFrom    // no From header
+	ErrHdrNoTo      // no To header
+	ErrHdrNoFromTag // in-dialog message without a From tag
+	ErrHdrBadFLine  // malformed first line (request or status line)
+	ErrHdrBadVer    // unsupported SIP version
 )
 
 // error values corresp. to each ErrorHdr value: this way the interface
@@ -58,6 +68,16 @@ var err2ErrorVal = [...]error{
 	ErrHdrBug,
 	ErrConvBug,
 	ErrHdrTooManyVals,
+	ErrHdrKeepAlive,
+	ErrHdrBadCLen,
+	ErrHdrNoCallID,
+	ErrHdrNoCSeq,
+	ErrHdrBadCSeq,
+	ErrHdrNoFrom,
+	ErrHdrNoTo,
+	ErrHdrNoFromTag,
+	ErrHdrBadFLine,
+	ErrHdrBadVer,
 }
 
 var errHdrStr = [...]string{
@@ -79,6 +99,16 @@ var errHdrStr = [...]string{
 	ErrHdrBug:          "internal BUG while parsing header",
 	ErrConvBug:         "error conversion BUG",
 	ErrHdrTooManyVals:  "too many values for the header",
+	ErrHdrKeepAlive:    "keepalive (no message, only CRLF)",
+	ErrHdrBadCLen:      "Content-Length does not match the body length",
+	ErrHdrNoCallID:     "no Call-ID header in message",
+	ErrHdrNoCSeq:       "no CSeq header in message",
+	ErrHdrBadCSeq:      "CSeq method does not match the request method",
+	ErrHdrNoFrom:       "no From header in message",
+	ErrHdrNoTo:         "no To header in message",
+	ErrHdrNoFromTag:    "no From tag in an in-dialog message",
+	ErrHdrBadFLine:     "malformed first line",
+	ErrHdrBadVer:       "unsupported SIP version",
 }
 
 func (e ErrorHdr) Error() string {
@@ -9,7 +9,42 @@ package sipsp
 // ParseExpiresVal parses an Expires header value, starting at offs in buf.
 // It returns a new offset pointing after the part that was parsed and an
 // error.
+// Unlike ParseUIntVal(), values bigger then 2^32-1 are not an error, they
+// are truncated to 2^32-1 (rfc3261 20.19).
 // For more information see ParseUIntVal().
 func ParseExpiresVal(buf []byte, offs int, pcl *PUIntBody) (int, ErrorHdr) {
-	return ParseUIntVal(buf, offs, pcl)
+	return ParseUIntHeaderVal(buf, offs, pcl, PUIntOpts{Clamp: true})
+}
+
+// ExpiresVal returns the Expires header value and whether the header is
+// present.
+// Unlike for PFromBody.ExpiresVal(), there is no separate validity flag:
+// an Expires header with an invalid value fails the message parsing.
+func (hv *PHdrVals) ExpiresVal() (val uint32, present bool) {
+	if !hv.Expires.Parsed() {
+		return 0, false
+	}
+	return hv.Expires.UIVal, true
+}
+
+// ParseMaxFwdVal parses a Max-Forwards header value, starting at offs in
+// buf.
+// It returns a new offset pointing after the part that was parsed and an
+// error.
+// For more information see ParseUIntVal().
+func ParseMaxFwdVal(buf []byte, offs int, pcl *PUIntBody) (int, ErrorHdr) {
+	return ParseUIntHeaderVal(buf, offs, pcl, PUIntOpts{})
+}
+
+// IntervalTooBrief returns the Min-Expires header value and true if the
+// message is a 423 (Interval Too Brief) reply containing a Min-Expires
+// header (RFC3261 10.3), meaning the request was rejected because of a too
+// short expires interval (and not e.g. an authentication failure).
+// It returns 0 and false otherwise.
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) IntervalTooBrief() (uint32, bool) {
+	if m.Request() || m.FL.Status != 423 || !m.PV.MinExpires.Parsed() {
+		return 0, false
+	}
+	return m.PV.MinExpires.UIVal, true
 }
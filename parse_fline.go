@@ -69,6 +69,19 @@ const (
 // constant arrays
 var sipVer = []byte("SIP/2.0")    // sip version
 var sipVerSP = []byte("SIP/2.0 ") // sip version including space
+var sipVerPrefix = []byte("SIP/") // any sip version start
+
+// trimReason removes leading and trailing whitespace from the reply reason.
+// An empty reason will be a 0 length PField pointing at the line end.
+func (pl *PFLine) trimReason(buf []byte) {
+	s := int(pl.Reason.Offs)
+	e := s + int(pl.Reason.Len)
+	for ; s < e && (buf[s] == ' ' || buf[s] == '\t'); s++ {
+	}
+	for ; e > s && (buf[e-1] == ' ' || buf[e-1] == '\t'); e-- {
+	}
+	pl.Reason.Set(s, e)
+}
 
 // ParseFLine parses the request/response line (first line) of a SIP message.
 // The parameters are: a message buffer, the offset in the buffer where the
@@ -79,6 +92,19 @@ var sipVerSP = []byte("SIP/2.0 ") // sip version including space
 // buf[offs:] ErrHdrMoreBytes will be returned and this function can be called
 // again when more bytes are available, with the same buffer, the returned
 // offset ("continue point") and the same PFLine structure.
+// Only the first line is parsed, so it can be used on a complete message
+// for cheap pre-filtering before calling ParseSIPMsg() or ParseHeaders()
+// (e.g. to drop OPTIONS early based on pl.MethodNo). On success pl contains
+// the method (MethodNo & Method), the request URI and the version for
+// requests, or the version, the status (Status & StatusCode) and the reason
+// for replies, and the returned offset points to the start of the first
+// header.
+// The reply reason is trimmed (leading and trailing whitespace removed) and
+// it can be empty (a missing space between the status and CRLF is
+// tolerated).
+// A malformed first line (e.g. missing request URI or non-numeric status)
+// results in ErrHdrBadFLine and a SIP version different from SIP/2.0 in
+// ErrHdrBadVer (the version is available in pl.Version).
 func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 
 	// grammar:
@@ -99,18 +125,22 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 			i += l
 			// no need to check again for length, initial length check includes
 			// space for status code
-			if buf[i+3] != ' ' ||
+			if (buf[i+3] != ' ' && buf[i+3] != '\t' &&
+				buf[i+3] != '\r' && buf[i+3] != '\n') ||
 				!((buf[i] >= '0' && buf[i] <= '9') &&
 					(buf[i+1] >= '0' && buf[i+1] <= '9') &&
 					(buf[i+2] >= '0' && buf[i+2] <= '9')) {
 				// non numerical, too big or too small status
-				return i, ErrHdrBadChar
+				return i, ErrHdrBadFLine
 			}
 			pl.StatusCode.Set(i, i+3)
 			pl.Status =
 				uint16(buf[i]-'0')*100 + uint16(buf[i+1]-'0')*10 +
 					uint16(buf[i+2]-'0')
-			i += 4 // skip over status + space
+			i += 3 // skip over status
+			if buf[i] == ' ' || buf[i] == '\t' {
+				i++ // skip over space
+			} // else CR or LF: missing space and empty reason, accept it
 			pl.Reason.Set(i, i)
 			pl.state = flRplReason
 			var err ErrorHdr
@@ -119,6 +149,7 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 				return i, err // could be moreBytes
 			}
 			pl.Reason.Extend(i - crl)
+			pl.trimReason(buf)
 			goto endOk
 		}
 		// request => skip over the 1st token
@@ -131,12 +162,19 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 			goto moreBytes
 		}
 		if buf[i] != ' ' { // '\t' , CR or LF => error
-			return i, ErrHdrBadChar
+			return i, ErrHdrBadFLine
 		}
 		pl.Method.Extend(i)
 		if pl.Method.Empty() {
 			goto errEmptyTok
 		}
+		if _, match := bytescase.Prefix(sipVerPrefix,
+			pl.Method.Get(buf)); match {
+			// reply with a different version
+			pl.Version = pl.Method
+			pl.Method.Reset()
+			return int(pl.Version.Offs), ErrHdrBadVer
+		}
 		pl.MethodNo = GetMethodNo(pl.Method.Get(buf))
 		i++
 		pl.state = flReqURI
@@ -148,7 +186,7 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 			goto moreBytes
 		}
 		if buf[i] != ' ' { // '\t' , CR or LF => error
-			return i, ErrHdrBadChar
+			return i, ErrHdrBadFLine
 		}
 		pl.URI.Extend(i)
 		if pl.URI.Empty() {
@@ -164,12 +202,15 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 			goto moreBytes
 		}
 		if buf[i] != '\r' && buf[i] != '\n' { // ' ' or '\t' at the end => error
-			return i, ErrHdrBadChar
+			return i, ErrHdrBadFLine
 		}
 		pl.Version.Extend(i)
 		if pl.Version.Empty() {
 			goto errEmptyTok
 		}
+		if !bytescase.CmpEq(pl.Version.Get(buf), sipVer) {
+			return int(pl.Version.Offs), ErrHdrBadVer
+		}
 		pl.state = flCRLF
 		fallthrough
 	case flCRLF:
@@ -187,6 +228,7 @@ func ParseFLine(buf []byte, offs int, pl *PFLine) (int, ErrorHdr) {
 			return i, err // could be moreBytes
 		}
 		pl.Reason.Extend(i - crl)
+		pl.trimReason(buf)
 	}
 endOk:
 	pl.state = flFIN
@@ -194,5 +236,5 @@ endOk:
 moreBytes:
 	return i, ErrHdrMoreBytes
 errEmptyTok:
-	return i, ErrHdrBadChar
+	return i, ErrHdrBadFLine
 }
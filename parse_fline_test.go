@@ -97,7 +97,7 @@ func TestParseFLine(t *testing.T) {
 		} else {
 			c.v = []byte(c.t1)
 			c.sc = []byte(c.t2)
-			c.r = []byte(c.t3)
+			c.r = bytes.Trim([]byte(c.t3), " \t")
 		}
 		testParseFLinePieces(t, b, 0, &c.pflERes, 10)
 	}
@@ -190,3 +190,85 @@ func testParseFLinePieces(t *testing.T, buf []byte, offs int, e *pflERes, n int)
 	}
 	testParseFLineExp(t, buf, o, &fl, e)
 }
+
+func TestParseFLineFullMsg(t *testing.T) {
+	type testCase struct {
+		m      string
+		method SIPMethod
+		uri    string
+		status uint16
+		reason string
+	}
+	tests := [...]testCase{
+		{m: "OPTIONS sip:ping@10.0.0.1 SIP/2.0\r\nVia: SIP/2.0/UDP h\r\n\r\n",
+			method: MOptions, uri: "sip:ping@10.0.0.1"},
+		{m: "SIP/2.0 404 Not Found\r\nVia: SIP/2.0/UDP h\r\n\r\n",
+			status: 404, reason: "Not Found"},
+		{m: "SIP/2.0 480 Temporarily Unavailable\r\nVia: SIP/2.0/UDP h\r\n\r\n",
+			status: 480, reason: "Temporarily Unavailable"},
+		{m: "SIP/2.0 480  Temporarily \t Unavailable \t\r\nVia: h\r\n\r\n",
+			status: 480, reason: "Temporarily \t Unavailable"},
+		// empty reason
+		{m: "SIP/2.0 200 \r\nVia: SIP/2.0/UDP h\r\n\r\n",
+			status: 200, reason: ""},
+		// empty reason, missing space
+		{m: "SIP/2.0 200\r\nVia: SIP/2.0/UDP h\r\n\r\n",
+			status: 200, reason: ""},
+	}
+	for _, tc := range tests {
+		var fl PFLine
+		buf := []byte(tc.m)
+		o, err := ParseFLine(buf, 0, &fl)
+		if err != 0 {
+			t.Fatalf("ParseFLine(%q) failed: %d (%q)", buf, err, err)
+		}
+		if !bytes.HasPrefix(buf[o:], []byte("Via:")) {
+			t.Errorf("ParseFLine(%q): returned offset %d does not point to"+
+				" the first header (%q)", buf, o, buf[o:])
+		}
+		if fl.MethodNo != tc.method || string(fl.URI.Get(buf)) != tc.uri ||
+			fl.Status != tc.status || string(fl.Reason.Get(buf)) != tc.reason ||
+			string(fl.Version.Get(buf)) != "SIP/2.0" {
+			t.Errorf("ParseFLine(%q): method %q uri %q status %d reason %q"+
+				" version %q", buf, fl.MethodNo, fl.URI.Get(buf), fl.Status,
+				fl.Reason.Get(buf), fl.Version.Get(buf))
+		}
+	}
+}
+
+func TestParseFLineErrors(t *testing.T) {
+	type testCase struct {
+		l   string
+		err ErrorHdr
+		ver string // expected version, if known
+	}
+	tests := [...]testCase{
+		{"SIP/1.0 200 OK\r\n", ErrHdrBadVer, "SIP/1.0"},
+		{"SIP/3.0 180 Ringing\r\n", ErrHdrBadVer, "SIP/3.0"},
+		{"INVITE sip:bob@biloxi.com SIP/1.0\r\n", ErrHdrBadVer, "SIP/1.0"},
+		{"GET /index.html HTTP/1.1\r\n", ErrHdrBadVer, "HTTP/1.1"},
+		{"INVITE sip:bob@biloxi.com sip/2.0\r\n", 0, "sip/2.0"},
+		// missing uri
+		{"INVITE SIP/2.0\r\nVia: SIP/2.0/UDP h\r\n", ErrHdrBadFLine, ""},
+		{"INVITE  SIP/2.0\r\nVia: SIP/2.0/UDP h\r\n", ErrHdrBadFLine, ""},
+		// garbage
+		{"this is not a sip message\r\n", ErrHdrBadFLine, ""},
+		{"\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03 \x01\r\n",
+			ErrHdrBadFLine, ""},
+		{"SIP/2.0 2x0 OK\r\n", ErrHdrBadFLine, ""},
+	}
+	for _, tc := range tests {
+		var fl PFLine
+		buf := []byte(tc.l)
+		_, err := ParseFLine(buf, 0, &fl)
+		if err != tc.err {
+			t.Errorf("ParseFLine(%q) = %d (%q) expected %d (%q)",
+				buf, err, err, tc.err, tc.err)
+			continue
+		}
+		if tc.ver != "" && string(fl.Version.Get(buf)) != tc.ver {
+			t.Errorf("ParseFLine(%q): version %q expected %q",
+				buf, fl.Version.Get(buf), tc.ver)
+		}
+	}
+}
@@ -22,8 +22,11 @@ type PFromBody struct {
 	LR         bool // route ;lr present
 	HasExpires bool // expires present
 	Type       HdrT
-	Q          uint16 // contact q * 1000
-	Expires    uint32 // contact expires
+	Q          uint16   // contact q * 1000
+	Expires    uint32   // contact expires
+	ExpiresErr ErrorHdr // error parsing the expires value, 0 if valid
+	Instance   PField   // contact +sip.instance value, without quotes
+	RegID      uint32   // contact reg-id (RFC5626), 0 if not present
 	Params     PField
 	V          PField   // complete value, trimmed
 	ParamErr   ErrorHdr // error parsing the params
@@ -36,6 +39,16 @@ func (fv *PFromBody) Reset() {
 	*fv = PFromBody{}
 }
 
+// ExpiresVal returns the expires parameter value, whether the parameter
+// is present and whether the value is valid.
+// For an invalid (non-numeric or empty) value, val will be 0, present
+// true and valid false and it is up to the caller to choose a default
+// (e.g. DefaultRegExpires for a REGISTER request or an error for a reply).
+// Values bigger then 2^32-1 are truncated to 2^32-1.
+func (fv *PFromBody) ExpiresVal() (val uint32, present, valid bool) {
+	return fv.Expires, fv.HasExpires, fv.HasExpires && fv.ExpiresErr == 0
+}
+
 // Empty returns true if nothing has been parsed yet.
 func (fv *PFromBody) Empty() bool {
 	return fv.state == fbInit
@@ -51,6 +64,80 @@ func (fv *PFromBody) Pending() bool {
 	return fv.state != fbFIN && fv.state != fbInit
 }
 
+// DisplayName returns the "clean" display name, appending it to dst[:0]
+// (dst can be nil, in which case a new slice will be allocated).
+// buf is the buffer the parsed values point into.
+// Leading and trailing whitespace is removed, the outer quotes are
+// stripped and the quoted-pairs are unescaped (e.g. "J Rosenberg \""
+// becomes J Rosenberg "). Outside quotes each linear whitespace
+// sequence (including line folding) is replaced by a single space and
+// inside quotes line breaks are removed.
+func (fv *PFromBody) DisplayName(buf, dst []byte) []byte {
+	n := fv.Name.Get(buf)
+	dst = dst[:0]
+	quoted := false
+	ws := false // whitespace seen, but not yet added
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if quoted {
+			switch c {
+			case '"':
+				quoted = false
+			case '\\':
+				if i+1 < len(n) {
+					i++
+					dst = append(dst, n[i])
+				}
+			case '\r', '\n':
+				// skip over line folding
+			default:
+				dst = append(dst, c)
+			}
+			continue
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			ws = true
+			continue
+		}
+		if ws && len(dst) > 0 {
+			dst = append(dst, ' ')
+		}
+		ws = false
+		if c == '"' {
+			quoted = true
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// Trim removes leading and trailing whitespace (including line folding)
+// from the parsed Name and Params fields (URI and Tag are always trimmed by
+// the parser). buf is the buffer the parsed values point into.
+// It should be called only after parsing finished (Parsed() returns true).
+func (fv *PFromBody) Trim(buf []byte) {
+	fv.Name = TrimLWS(buf, fv.Name)
+	fv.Params = TrimLWS(buf, fv.Params)
+}
+
+// HasValidTag returns true if a non-empty tag parameter is present and
+// it is a valid rfc3261 token (the From tag is mandatory in requests and
+// a missing or malformed tag is common in scanner traffic).
+// buf is the buffer the parsed values point into.
+func (fv *PFromBody) HasValidTag(buf []byte) bool {
+	if fv.Tag.Empty() {
+		return false
+	}
+	for _, c := range fv.Tag.Get(buf) {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
 // PFromIState contains ParseFrom internal state info (private).
 type PFromIState struct {
 	state  uint8 // internal state
@@ -107,7 +194,8 @@ func ParseFromVal(buf []byte, offs int, pfrom *PFromBody) (int, ErrorHdr) {
 
 func multipleValsOk(h HdrT) bool {
 	switch h {
-	case HdrContact, HdrRecordRoute, HdrRoute, HdrPAI:
+	case HdrContact, HdrRecordRoute, HdrRoute, HdrPAI, HdrCallInfo,
+		HdrAlertInfo:
 		return true
 	}
 	return false
@@ -132,7 +220,8 @@ func multipleValsOk(h HdrT) bool {
 // error is returned.
 // WARNING: for now Name and Params might contain extra trailing whitespace
 //          (no attempt is made to eliminate it). URI and Tag are
-//           auto-trimmed.
+//           auto-trimmed. Use PFromBody.Trim() after parsing to get
+//           trimmed Name and Params.
 func ParseNameAddrPVal(h HdrT, buf []byte, offs int, pfrom *PFromBody) (int, ErrorHdr) {
 	// Name-addr <addr>;params
 	// internal parser state
@@ -589,6 +678,8 @@ func setFromParamVal(buf []byte, pf *PFromBody) ErrorHdr {
 	expires := [...]byte{'e', 'x', 'p', 'i', 'r', 'e', 's'}
 	q := [...]byte{'q'}
 	lr := [...]byte{'l', 'r'}
+	instance := []byte("+sip.instance")
+	regid := []byte("reg-id")
 
 	if (pf.pstart < pf.pend) && (pf.vstart < pf.vend) {
 		// for now only tag=val is recognized, hard-wired
@@ -598,16 +689,11 @@ func setFromParamVal(buf []byte, pf *PFromBody) ErrorHdr {
 		} else if ((pf.pend - pf.pstart) == len(expires)) &&
 			bytescase.CmpEq(buf[pf.pstart:pf.pend], expires[:]) {
 			pf.HasExpires = true
-			exp, e := pUInt64Val(buf[pf.vstart:pf.vend])
-			if exp < uint64(^uint32(0)) {
-				pf.Expires = uint32(exp)
-			} else {
-				// truncate to max. uint32 (rfc3261)
-				pf.Expires = ^uint32(0)
-			}
-			// in REGISTER if value is not parsable the default is 3600
-			// however this won't be good for replies
-			err = e
+			// on error the default depends on the message (e.g. 3600 for
+			// a REGISTER request), so it's left to the caller
+			// (see ExpiresVal())
+			pf.Expires, err = pExpiresVal(buf[pf.vstart:pf.vend])
+			pf.ExpiresErr = err
 
 		} else if ((pf.pend - pf.pstart) == len(q)) &&
 			bytescase.CmpEq(buf[pf.pstart:pf.pend], q[:]) {
@@ -644,12 +730,41 @@ func setFromParamVal(buf []byte, pf *PFromBody) ErrorHdr {
 		} else if ((pf.pend - pf.pstart) == len(lr)) &&
 			bytescase.CmpEq(buf[pf.pstart:pf.pend], lr[:]) {
 			pf.LR = true
+		} else if ((pf.pend - pf.pstart) == len(instance)) &&
+			bytescase.CmpEq(buf[pf.pstart:pf.pend], instance) {
+			// strip the quotes
+			vs, ve := pf.vstart, pf.vend
+			if ve-vs >= 2 && buf[vs] == '"' && buf[ve-1] == '"' {
+				vs++
+				ve--
+			}
+			pf.Instance.Set(vs, ve)
+		} else if ((pf.pend - pf.pstart) == len(regid)) &&
+			bytescase.CmpEq(buf[pf.pstart:pf.pend], regid) {
+			id, e := pUInt64Val(buf[pf.vstart:pf.vend])
+			if e == 0 && (id == 0 || id > uint64(^uint32(0))) {
+				e = ErrHdrValBad
+			}
+			if e == 0 {
+				pf.RegID = uint32(id)
+			} else {
+				err = e
+				pf.ParamErr = err
+				pf.ErrOffs = OffsT(pf.vstart)
+			}
 		}
 	} else if (pf.pstart < pf.pend) && (pf.vstart == pf.vend) {
 		// lr normally has no value
 		if ((pf.pend - pf.pstart) == len(lr)) &&
 			bytescase.CmpEq(buf[pf.pstart:pf.pend], lr[:]) {
 			pf.LR = true
+		} else if ((pf.pend - pf.pstart) == len(expires)) &&
+			bytescase.CmpEq(buf[pf.pstart:pf.pend], expires[:]) {
+			// expires present, but with no value
+			pf.HasExpires = true
+			pf.Expires = 0
+			pf.ExpiresErr = ErrHdrEmpty
+			err = ErrHdrEmpty
 		}
 	} else {
 		err = ErrHdrValBad
@@ -663,6 +778,29 @@ func setFromParamVal(buf []byte, pf *PFromBody) ErrorHdr {
 	return err
 }
 
+// pExpiresVal converts an expires value to a number.
+// Values bigger then 2^32-1 are truncated to 2^32-1 (see rfc3261 10.2.1.1).
+// It returns ErrHdrEmpty for an empty value and ErrHdrValNotNumber for a
+// non-numeric value.
+func pExpiresVal(b []byte) (uint32, ErrorHdr) {
+	var n uint64
+	if len(b) == 0 {
+		return 0, ErrHdrEmpty
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, ErrHdrValNotNumber
+		}
+		if n <= uint64(^uint32(0)) {
+			n = n*10 + uint64(c-'0')
+		}
+	}
+	if n > uint64(^uint32(0)) {
+		n = uint64(^uint32(0))
+	}
+	return uint32(n), 0
+}
+
 func pUInt64Val(b []byte) (n uint64, err ErrorHdr) {
 
 	if len(b) > 20 {
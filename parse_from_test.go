@@ -529,3 +529,106 @@ func genFromBody(n, u, p, tagv string, random bool) (string, string) {
 	s += randLWS() // no end of header
 	return s, params
 }
+
+func TestPFromBodyDisplayName(t *testing.T) {
+	type testCase struct {
+		name string // raw display name
+		res  string // expected display name
+	}
+	tests := [...]testCase{
+		{"", ""},
+		{"Display Name", "Display Name"},
+		{"ShortName", "ShortName"},
+		{" WS Name Front", "WS Name Front"},
+		{"WS Name End 	", "WS Name End"},
+		{"CRLF\r\n Name", "CRLF Name"},
+		{"\r\n CRLF Name Front", "CRLF Name Front"},
+		{"CRLF Name End\r\n ", "CRLF Name End"},
+		{"  \r\n 	CRLF\r\n Name \r\n ", "CRLF Name"},
+		{"\"Anonymous\"", "Anonymous"},
+		{"\"00:42:60:e1:5c:a8\"", "00:42:60:e1:5c:a8"},
+		{"\"J Rosenberg \\\"\"", "J Rosenberg \""},
+		{"\"a\\\\b\"", "a\\b"},
+		{"\"\\\\\"", "\\"},
+		{"  \" Quoted  Spaces \"  ", " Quoted  Spaces "},
+		{"\"Folded\r\n Name\"", "Folded Name"},
+	}
+	var pf PFromBody
+	for _, tc := range tests {
+		b := []byte(tc.name + "<sip:u1@test.org>;tag=1234\r\n\r\n")
+		pf.Reset()
+		if _, err := ParseFromVal(b, 0, &pf); err != 0 {
+			t.Fatalf("ParseFromVal(%q) failed: %d (%q)", b, err, err)
+		}
+		if dn := pf.DisplayName(b, nil); string(dn) != tc.res {
+			t.Errorf("DisplayName() for %q = %q, expected %q",
+				tc.name, dn, tc.res)
+		}
+		// re-use dst
+		dst := make([]byte, 1, 100)
+		if dn := pf.DisplayName(b, dst); string(dn) != tc.res {
+			t.Errorf("DisplayName() for %q with dst = %q, expected %q",
+				tc.name, dn, tc.res)
+		}
+	}
+}
+
+func TestPFromBodyTrim(t *testing.T) {
+	type testCase struct {
+		val    string // header value
+		name   string // expected trimmed name
+		params string // expected trimmed params
+	}
+	tests := [...]testCase{
+		{"  Foo Bar   <sip:x@y>", "Foo Bar", ""},
+		{"Foo Bar\r\n <sip:x@y> ;tag=1 ; p=2  ", "Foo Bar", "tag=1 ; p=2"},
+		{"\"Foo Bar \" <sip:x@y>;tag=1\r\n ", "\"Foo Bar \"", "tag=1"},
+		{"<sip:x@y>;tag=1", "", "tag=1"},
+		{"sip:x@y ;tag=1", "", "tag=1"},
+	}
+	var pf PFromBody
+	for _, tc := range tests {
+		b := []byte(tc.val + "\r\n\r\n")
+		pf.Reset()
+		if _, err := ParseFromVal(b, 0, &pf); err != 0 {
+			t.Fatalf("ParseFromVal(%q) failed: %d (%q)", b, err, err)
+		}
+		uri := string(pf.URI.Get(b))
+		pf.Trim(b)
+		if string(pf.Name.Get(b)) != tc.name ||
+			string(pf.Params.Get(b)) != tc.params ||
+			string(pf.URI.Get(b)) != uri {
+			t.Errorf("Trim() for %q: name %q params %q uri %q,"+
+				" expected %q, %q, %q", tc.val, pf.Name.Get(b),
+				pf.Params.Get(b), pf.URI.Get(b), tc.name, tc.params, uri)
+		}
+	}
+}
+
+func TestPFromBodyHasValidTag(t *testing.T) {
+	type testCase struct {
+		val   string // header value
+		valid bool
+	}
+	tests := [...]testCase{
+		{"<sip:x@y>;tag=1928301774", true},
+		{"Foo <sip:x@y>;tag=a6c85cf-x.y!%*_+`'~", true},
+		{"<sip:x@y>", false},
+		{"<sip:x@y>;tag=", false},
+		{"<sip:x@y>;tag=ab@cd", false},
+		{"<sip:x@y>;tag=\"ab\"", false},
+		{"<sip:x@y>;foo=bar", false},
+	}
+	var pf PFromBody
+	for _, tc := range tests {
+		b := []byte(tc.val + "\r\n\r\n")
+		pf.Reset()
+		if _, err := ParseFromVal(b, 0, &pf); err != 0 {
+			t.Fatalf("ParseFromVal(%q) failed: %d (%q)", b, err, err)
+		}
+		if v := pf.HasValidTag(b); v != tc.valid {
+			t.Errorf("HasValidTag(%q) = %v expected %v (tag %q)",
+				tc.val, v, tc.valid, pf.Tag.Get(b))
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 type HdrT uint16
 
 // HdrFlags packs several header values into bit flags.
-type HdrFlags uint16
+type HdrFlags uint32
 
 // Reset initializes a HdrFlags.
 func (f *HdrFlags) Reset() {
@@ -73,6 +73,13 @@ const (
 	HdrRecordRoute
 	HdrRoute
 	HdrPAI
+	HdrCType
+	HdrCallInfo
+	HdrAlertInfo
+	HdrServer
+	HdrReplaces
+	HdrMinExpires
+	HdrPCV
 	HdrOther // generic, non recognized header
 )
 
@@ -91,6 +98,13 @@ const (
 	HdrRecordRouteF HdrFlags = 1 << HdrRecordRoute
 	HdrRouteF       HdrFlags = 1 << HdrRoute
 	HdrPAIF         HdrFlags = 1 << HdrPAI
+	HdrCTypeF       HdrFlags = 1 << HdrCType
+	HdrCallInfoF    HdrFlags = 1 << HdrCallInfo
+	HdrAlertInfoF   HdrFlags = 1 << HdrAlertInfo
+	HdrServerF      HdrFlags = 1 << HdrServer
+	HdrReplacesF    HdrFlags = 1 << HdrReplaces
+	HdrMinExpiresF  HdrFlags = 1 << HdrMinExpires
+	HdrPCVF         HdrFlags = 1 << HdrPCV
 	HdrOtherF       HdrFlags = 1 << HdrOther
 )
 
@@ -110,6 +124,13 @@ var hdrTStr = [...]string{
 	HdrRecordRoute: "Record-Router",
 	HdrRoute:       "Route",
 	HdrPAI:         "P-Asserted-Identity",
+	HdrCType:       "Content-Type",
+	HdrCallInfo:    "Call-Info",
+	HdrAlertInfo:   "Alert-Info",
+	HdrServer:      "Server",
+	HdrReplaces:    "Replaces",
+	HdrMinExpires:  "Min-Expires",
+	HdrPCV:         "P-Charging-Vector",
 	HdrOther:       "Generic",
 }
 
@@ -149,6 +170,14 @@ var hdrName2Type = [...]hdr2Type{
 	{n: []byte("record-route"), t: HdrRecordRoute},
 	{n: []byte("route"), t: HdrRoute},
 	{n: []byte("p-asserted-identity"), t: HdrPAI},
+	{n: []byte("content-type"), t: HdrCType},
+	{n: []byte("c"), t: HdrCType},
+	{n: []byte("call-info"), t: HdrCallInfo},
+	{n: []byte("alert-info"), t: HdrAlertInfo},
+	{n: []byte("server"), t: HdrServer},
+	{n: []byte("replaces"), t: HdrReplaces},
+	{n: []byte("min-expires"), t: HdrMinExpires},
+	{n: []byte("p-charging-vector"), t: HdrPCV},
 }
 
 const (
@@ -238,6 +267,11 @@ func (hl *HdrLst) Reset() {
 	hl.Hdrs = hdrs
 }
 
+// Truncated returns true if not all the headers found fit in Hdrs.
+func (hl *HdrLst) Truncated() bool {
+	return hl.N > len(hl.Hdrs)
+}
+
 // GetHdr returns the first parsed header of the requested type.
 // If no corresponding header was parsed it returns nil.
 func (hl *HdrLst) GetHdr(t HdrT) *Hdr {
@@ -260,6 +294,45 @@ func (hl *HdrLst) SetHdr(newhdr *Hdr) bool {
 	return false
 }
 
+// GetAll iterates over all the parsed headers with the given name, calling
+// f for each of them, in the order in which they appear in the message.
+// buf is the message buffer the headers point into. If name corresponds to
+// a known header type, the headers are matched on type (so both the long
+// and the compact form will be found), otherwise a case-insensitive
+// header name comparison is used (e.g. for X- headers).
+// The iteration stops if f returns false.
+// It returns ErrHdrOk on success or ErrHdrTrunc if not all the message
+// headers could be inspected (the message has more headers then
+// len(hl.Hdrs)).
+func (hl *HdrLst) GetAll(buf []byte, name []byte, f func(h *Hdr) bool) ErrorHdr {
+	if len(name) == 0 {
+		return ErrHdrEmpty
+	}
+	t := GetHdrType(name)
+	n := hl.N
+	if n > len(hl.Hdrs) {
+		n = len(hl.Hdrs)
+	}
+	for i := 0; i < n; i++ {
+		h := &hl.Hdrs[i]
+		if t != HdrOther {
+			if h.Type != t {
+				continue
+			}
+		} else if h.Type != HdrOther ||
+			!bytescase.CmpEq(h.Name.Get(buf), name) {
+			continue
+		}
+		if !f(h) {
+			break
+		}
+	}
+	if hl.N > len(hl.Hdrs) {
+		return ErrHdrTrunc
+	}
+	return ErrHdrOk
+}
+
 // PHBodies defines an interface for getting pointers to parsed bodies structs.
 type PHBodies interface {
 	GetFrom() *PFromBody
@@ -269,21 +342,35 @@ type PHBodies interface {
 	GetCLen() *PUIntBody
 	GetContacts() *PContacts
 	GetExpires() *PUIntBody
+	GetMaxFwd() *PUIntBody
+	GetMinExpires() *PUIntBody
 	GetPAIs() *PPAIs
+	GetCallInfos() *PInfos
+	GetAlertInfos() *PInfos
+	GetCustomHdrs() *PCustomHdrs
+	GetReplaces() *PReplacesBody
+	GetPCV() *PPCVBody
 	Reset()
 }
 
 // PHdrVals holds all the header specific parsed values structures.
 // (implements PHBodies)
 type PHdrVals struct {
-	From     PFromBody
-	To       PFromBody
-	Callid   PCallIDBody
-	CSeq     PCSeqBody
-	CLen     PUIntBody
-	Contacts PContacts
-	PAIs     PPAIs
-	Expires  PUIntBody
+	From       PFromBody
+	To         PFromBody
+	Callid     PCallIDBody
+	CSeq       PCSeqBody
+	CLen       PUIntBody
+	Contacts   PContacts
+	PAIs       PPAIs
+	Expires    PUIntBody
+	MaxFwd     PUIntBody
+	MinExpires PUIntBody
+	CallInfos  PInfos
+	AlertInfos PInfos
+	Custom     PCustomHdrs // registered custom headers, see RegisterHeader
+	Replaces   PReplacesBody
+	PCV        PPCVBody // P-Charging-Vector
 }
 
 // Reset re-initializes all the parsed values.
@@ -295,6 +382,13 @@ func (hv *PHdrVals) Reset() {
 	hv.CLen.Reset()
 	hv.Contacts.Reset()
 	hv.Expires.Reset()
+	hv.MaxFwd.Reset()
+	hv.MinExpires.Reset()
+	hv.CallInfos.Reset()
+	hv.AlertInfos.Reset()
+	hv.Custom.Reset()
+	hv.Replaces.Reset()
+	hv.PCV.Reset()
 }
 
 // Init initializes all the Contacts values to the passed contacsbuf
@@ -346,12 +440,54 @@ func (hv *PHdrVals) GetExpires() *PUIntBody {
 	return &hv.Expires
 }
 
+// GetMaxFwd returns a pointer to the parsed max-forwards value.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetMaxFwd() *PUIntBody {
+	return &hv.MaxFwd
+}
+
+// GetMinExpires returns a pointer to the parsed min-expires value.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetMinExpires() *PUIntBody {
+	return &hv.MinExpires
+}
+
 // GetPAIs returns a pointer to the parsed P-Asserted-Identity values.
 // It implements the PHBodies interface.
 func (hv *PHdrVals) GetPAIs() *PPAIs {
 	return &hv.PAIs
 }
 
+// GetCallInfos returns a pointer to the parsed Call-Info values.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetCallInfos() *PInfos {
+	return &hv.CallInfos
+}
+
+// GetAlertInfos returns a pointer to the parsed Alert-Info values.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetAlertInfos() *PInfos {
+	return &hv.AlertInfos
+}
+
+// GetCustomHdrs returns a pointer to the parsed custom headers values.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetCustomHdrs() *PCustomHdrs {
+	return &hv.Custom
+}
+
+// GetReplaces returns a pointer to the parsed replaces value.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetReplaces() *PReplacesBody {
+	return &hv.Replaces
+}
+
+// GetPCV returns a pointer to the parsed P-Charging-Vector value.
+// It implements the PHBodies interface.
+func (hv *PHdrVals) GetPCV() *PPCVBody {
+	return &hv.PCV
+}
+
 // MaxExpires returns the maximum expires time between all the contacts
 // and a possible Expire header.
 // If neither Contact: or Expire: header are present, it will return 0, false.
@@ -371,6 +507,28 @@ func (hv *PHdrVals) MaxExpires() (uint32, bool) {
 	return max, ok
 }
 
+// DefaultRegExpires is the default REGISTER expires value (RFC3261 10.2.1.1),
+// used if neither the contact nor the message specify an expires value.
+const DefaultRegExpires = 3600
+
+// EffectiveExpires returns the expires value for the contact c, applying
+// the RFC3261 10.2.1.1 precedence rules: an "expires" contact parameter
+// has precedence over the Expires header value, which in turn has
+// precedence over the passed default value def (e.g. DefaultRegExpires for
+// REGISTER requests). An invalid "expires" contact parameter is ignored.
+// c can be nil, in which case only the Expires header and def are used.
+func (hv *PHdrVals) EffectiveExpires(c *PFromBody, def uint32) uint32 {
+	if c != nil {
+		if exp, _, valid := c.ExpiresVal(); valid {
+			return exp
+		}
+	}
+	if hv.Expires.Parsed() {
+		return hv.Expires.UIVal
+	}
+	return def
+}
+
 // ParseHdrLine parses a header from a SIP message.
 // The parameters are: a message buffer, the offset in the buffer where the
 // parsing should start (or continue), a pointer to a Hdr structure that will
@@ -402,7 +560,11 @@ func ParseHdrLine(buf []byte, offs int, h *Hdr, hb PHBodies) (int, ErrorHdr) {
 		hCLen
 		hContact
 		hExpires
+		hMaxFwd
+		hMinExpires
 		hPAI
+		hCallInfo
+		hAlertInfo
 		hFIN
 	)
 
@@ -474,6 +636,22 @@ func ParseHdrLine(buf []byte, offs int, h *Hdr, hb PHBodies) (int, ErrorHdr) {
 						h.Val = expb.SVal
 					}
 				}
+			case HdrMaxFwd:
+				if mfb := hb.GetMaxFwd(); mfb != nil && !mfb.Parsed() {
+					h.state = hMaxFwd
+					n, err = ParseMaxFwdVal(buf, o, mfb)
+					if err == 0 { /* fix hdr.Val */
+						h.Val = mfb.SVal
+					}
+				}
+			case HdrMinExpires:
+				if meb := hb.GetMinExpires(); meb != nil && !meb.Parsed() {
+					h.state = hMinExpires
+					n, err = ParseExpiresVal(buf, o, meb)
+					if err == 0 { /* fix hdr.Val */
+						h.Val = meb.SVal
+					}
+				}
 			case HdrPAI:
 				if pais := hb.GetPAIs(); pais != nil {
 					if h.state != hPAI {
@@ -486,6 +664,24 @@ func ParseHdrLine(buf []byte, offs int, h *Hdr, hb PHBodies) (int, ErrorHdr) {
 						h.Val = pais.LastHVal
 					}
 				}
+			case HdrCallInfo, HdrAlertInfo:
+				infos := hb.GetCallInfos()
+				st := hCallInfo
+				if h.Type == HdrAlertInfo {
+					infos = hb.GetAlertInfos()
+					st = hAlertInfo
+				}
+				if infos != nil {
+					if h.state != st {
+						// new header found
+						infos.HNo++
+					}
+					h.state = st
+					n, err = ParseAllInfoValues(h.Type, buf, o, infos)
+					if err == 0 { /* fix hdr.Val */
+						h.Val = infos.LastHVal
+					}
+				}
 			}
 		}
 		return n, err
@@ -664,6 +860,22 @@ func ParseHdrLine(buf []byte, offs int, h *Hdr, hb PHBodies) (int, ErrorHdr) {
 				h.state = hFIN
 			}
 			return n, err
+		case hMaxFwd: // continue max-forwards parsing
+			mfb := hb.GetMaxFwd()
+			n, err := ParseMaxFwdVal(buf, i, mfb)
+			if err == 0 { /* fix hdr.Val */
+				h.Val = mfb.SVal
+				h.state = hFIN
+			}
+			return n, err
+		case hMinExpires: // continue min-expires parsing
+			meb := hb.GetMinExpires()
+			n, err := ParseExpiresVal(buf, i, meb)
+			if err == 0 { /* fix hdr.Val */
+				h.Val = meb.SVal
+				h.state = hFIN
+			}
+			return n, err
 		case hPAI: // continue contact parsing
 			pais := hb.GetPAIs()
 			n, err := ParseAllPAIValues(buf, i, pais)
@@ -672,6 +884,17 @@ func ParseHdrLine(buf []byte, offs int, h *Hdr, hb PHBodies) (int, ErrorHdr) {
 				h.state = hFIN
 			}
 			return n, err
+		case hCallInfo, hAlertInfo: // continue call-info/alert-info parsing
+			infos := hb.GetCallInfos()
+			if h.state == hAlertInfo {
+				infos = hb.GetAlertInfos()
+			}
+			n, err := ParseAllInfoValues(h.Type, buf, i, infos)
+			if err == 0 { /* fix hdr.Val */
+				h.Val = infos.LastHVal
+				h.state = hFIN
+			}
+			return n, err
 		default: // unexpected state
 			return i, ErrHdrBug
 		}
@@ -680,12 +903,42 @@ moreBytes:
 	return i, ErrHdrMoreBytes
 endOfHdr:
 	h.state = hFIN
+	if hb != nil {
+		parseFullVal(buf, h, hb)
+	}
 	return i + crl, 0
 errBadChar:
 errEmptyTok:
 	return i, ErrHdrBadChar
 }
 
+// parseFullVal parses the values of the headers that need the complete
+// header value (unlike the header specific parsers used in ParseHdrLine(),
+// they cannot resume parsing when more bytes are available), so they are
+// parsed only after the end of the header was found: custom headers (see
+// RegisterHeader()) and the first Replaces and P-Charging-Vector headers.
+// Parsing errors are saved in the parsed values (Err) and do not affect
+// the header parsing.
+func parseFullVal(buf []byte, h *Hdr, hb PHBodies) {
+	v := buf[:h.Val.Offs+h.Val.Len] // the value ends at the input end
+	switch h.Type {
+	case HdrOther:
+		if len(customHdrs) > 0 {
+			if c := hb.GetCustomHdrs(); c != nil {
+				parseCustomHdr(buf, h, c)
+			}
+		}
+	case HdrReplaces:
+		if r := hb.GetReplaces(); r != nil && !r.done {
+			ParseReplacesVal(v, int(h.Val.Offs), r)
+		}
+	case HdrPCV:
+		if pcv := hb.GetPCV(); pcv != nil && !pcv.done {
+			ParsePCVVal(v, int(h.Val.Offs), pcv)
+		}
+	}
+}
+
 // ParseHeaders parses all the headers till end of header marker (double CRLF).
 // It returns an offset after parsed headers and no error (0) on success.
 // Special error values: ErrHdrMoreBytes - more data needed, call again
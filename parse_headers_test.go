@@ -133,6 +133,20 @@ var testsHeaders = [...]testCase{
 	{n: "P-Asserted-Identity", b: "FooBar Baz <baz@bar.com>",
 		eRes: eRes{err: 0, t: HdrPAI}},
 	{n: "Expires", b: "3600", eRes: eRes{err: 0, t: HdrExpires}},
+	{n: "Max-Forwards", b: "70", eRes: eRes{err: 0, t: HdrMaxFwd}},
+	{n: "Min-Expires", b: "3600", eRes: eRes{err: 0, t: HdrMinExpires}},
+	{n: "P-Charging-Vector", b: "icid-value=1234bc9876e;orig-ioi=home1.net",
+		eRes: eRes{err: 0, t: HdrPCV}},
+	{n: "Server", b: "Foo/1.0", eRes: eRes{err: 0, t: HdrServer}},
+	{n: "Replaces", b: "a@b;to-tag=1;from-tag=2",
+		eRes: eRes{err: 0, t: HdrReplaces}},
+	{n: "Content-Type", b: "application/sdp",
+		eRes: eRes{err: 0, t: HdrCType}},
+	{n: "c", b: "text/plain;charset=UTF-8", eRes: eRes{err: 0, t: HdrCType}},
+	{n: "Alert-Info", b: "<http://www.example.com/sounds/moo.wav>;info=x",
+		eRes: eRes{err: 0, t: HdrAlertInfo}},
+	{n: "Call-Info", b: "<http://wwww.example.com/alice/photo.jpg>;purpose=icon, <http://www.example.com/alice/>;purpose=info",
+		eRes: eRes{err: 0, t: HdrCallInfo}},
 	{n: "Foo", b: "generic header", eRes: eRes{err: 0, t: HdrOther}},
 }
 
@@ -0,0 +1,170 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"github.com/intuitivelabs/bytescase"
+)
+
+// PInfoBody contains a parsed Call-Info or Alert-Info value.
+type PInfoBody struct {
+	PFromBody         // parsed name-addr value (URI & Params)
+	Purpose    PField // Call-Info purpose parameter value
+	Appearance PField // Call-Info appearance parameter value (RFC7463)
+	Info       PField // Alert-Info info parameter value (RFC7462)
+}
+
+// Reset re-initializes the parsing state and the parsed values.
+func (iv *PInfoBody) Reset() {
+	*iv = PInfoBody{}
+}
+
+// PInfos contains the parsed Call-Info or Alert-Info header values.
+type PInfos struct {
+	Vals     [2]PInfoBody // parsed values (max 2)
+	N        int          // no of _values_ found, can be > len(Vals)
+	HNo      int          // no of different _headers_ found
+	LastHVal PField       // values part of the last _header_ parsed
+	last     PInfoBody    // used if no space in Vals, as tmp state keeping
+}
+
+// VNo returns the number of parsed values in c.Vals (0-2).
+func (c *PInfos) VNo() int {
+	if c.N > len(c.Vals) {
+		return len(c.Vals)
+	}
+	return c.N
+}
+
+// GetInfo returns the requested parsed value or nil.
+func (c *PInfos) GetInfo(n int) *PInfoBody {
+	if c.VNo() > n {
+		return &c.Vals[n]
+	}
+	return nil
+}
+
+// More returns true if there are more values that did not fit in Vals.
+func (c *PInfos) More() bool {
+	return c.N > len(c.Vals)
+}
+
+// Reset re-initializes the parsed values.
+func (c *PInfos) Reset() {
+	*c = PInfos{}
+}
+
+// Empty returns true if no values have been parsed.
+func (c *PInfos) Empty() bool {
+	return c.N == 0
+}
+
+// Parsed returns true if there are some parsed values.
+func (c *PInfos) Parsed() bool {
+	return c.N > 0
+}
+
+// setInfoParams fills the purpose, appearance and info values from the
+// already parsed iv.Params.
+func setInfoParams(buf []byte, iv *PInfoBody) {
+	const flags = POptParamSemiSepF | POptInputEndF
+	var param PTokParam
+
+	if iv.Params.Empty() {
+		return
+	}
+	params := iv.Params.Get(buf)
+	offs := 0
+	if params[0] == ';' {
+		offs++
+	}
+	for {
+		next, err := ParseTokenParam(params, offs, &param, flags)
+		if err != 0 && err != ErrHdrMoreValues && err != ErrHdrEOH {
+			break
+		}
+		val := param.Val
+		val.Offs += iv.Params.Offs
+		switch name := param.Name.Get(params); {
+		case bytescase.CmpEq(name, []byte("purpose")):
+			iv.Purpose = val
+		case bytescase.CmpEq(name, []byte("appearance")):
+			iv.Appearance = val
+		case bytescase.CmpEq(name, []byte("info")):
+			iv.Info = val
+		}
+		if err != ErrHdrMoreValues {
+			break
+		}
+		offs = next
+		param.Reset()
+	}
+}
+
+// ParseOneInfo parses the content of one Call-Info or Alert-Info value
+// (depending on h), found at offset offs in buf. iv will be filled with the
+// parsed content.
+// See ParseNameAddrPVal() for more information.
+func ParseOneInfo(h HdrT, buf []byte, offs int, iv *PInfoBody) (int, ErrorHdr) {
+	next, err := ParseNameAddrPVal(h, buf, offs, &iv.PFromBody)
+	if err == 0 || err == ErrHdrMoreValues {
+		if iv.Star {
+			return next, ErrHdrValBad
+		}
+		setInfoParams(buf, iv)
+	}
+	return next, err
+}
+
+// ParseAllInfoValues tries to parse all the values in a Call-Info or
+// Alert-Info header (depending on h) situated at offs in buf and add them
+// to the passed PInfos.
+// It can return ErrHdrMoreBytes if more data is needed (the value is not
+// fully contained in buf).
+func ParseAllInfoValues(h HdrT, buf []byte, offs int, c *PInfos) (int, ErrorHdr) {
+	var next int
+	var err ErrorHdr
+	var iv *PInfoBody
+
+	if c.N >= len(c.Vals) {
+		if c.last.Parsed() {
+			c.last.Reset()
+		}
+	}
+	for {
+		if c.N < len(c.Vals) {
+			iv = &c.Vals[c.N]
+		} else {
+			iv = &c.last
+		}
+		next, err = ParseOneInfo(h, buf, offs, iv)
+		switch err {
+		case 0, ErrHdrMoreValues:
+			if c.N == 0 {
+				c.LastHVal = iv.V
+			} else {
+				c.LastHVal.Extend(int(iv.V.Offs + iv.V.Len))
+			}
+			c.N++ // next value, continue parsing
+			if err == ErrHdrMoreValues {
+				offs = next
+				if iv == &c.last {
+					c.last.Reset() // prepare for next value
+				}
+				continue // get next value
+			}
+		case ErrHdrMoreBytes:
+			// do nothing, just for readability
+		default:
+			if iv == &c.last {
+				c.last.Reset() // prepare for next value
+			}
+		}
+		break
+	}
+	return next, err
+}
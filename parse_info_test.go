@@ -0,0 +1,70 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"testing"
+)
+
+func TestParseInfoHeaders(t *testing.T) {
+	type expInfo struct {
+		uri, purpose, appearance, info string
+	}
+	buf := unescapeCRLF(`INVITE sip:bob@biloxi.com SIP/2.0\r
+Alert-Info: <http://www.example.com/sounds/moo.wav>;info=alert-autoanswer\r
+Call-Info: <http://wwww.example.com/alice/photo.jpg> ;purpose=icon,\r
+  <http://www.example.com/alice/>;purpose=info\r
+Call-Info: <sip:alice@example.com>;appearance-index=1;appearance=2\r
+CSeq: 1 INVITE\r
+\r
+`)
+	alert := []expInfo{
+		{"http://www.example.com/sounds/moo.wav", "", "", "alert-autoanswer"},
+	}
+	call := []expInfo{
+		{"http://wwww.example.com/alice/photo.jpg", "icon", "", ""},
+		{"http://www.example.com/alice/", "info", "", ""},
+	}
+	var msg PSIPMsg
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+	check := func(name string, c *PInfos, exp []expInfo, n, hno int) {
+		if c.N != n || c.HNo != hno || c.VNo() != len(exp) {
+			t.Errorf("%s: N %d HNo %d VNo %d, expected %d, %d, %d",
+				name, c.N, c.HNo, c.VNo(), n, hno, len(exp))
+			return
+		}
+		for i, e := range exp {
+			v := c.GetInfo(i)
+			if string(v.URI.Get(buf)) != e.uri ||
+				string(v.Purpose.Get(buf)) != e.purpose ||
+				string(v.Appearance.Get(buf)) != e.appearance ||
+				string(v.Info.Get(buf)) != e.info {
+				t.Errorf("%s[%d]: uri %q purpose %q appearance %q info %q,"+
+					" expected %v", name, i, v.URI.Get(buf),
+					v.Purpose.Get(buf), v.Appearance.Get(buf),
+					v.Info.Get(buf), e)
+			}
+		}
+	}
+	check("Alert-Info", &msg.PV.AlertInfos, alert, 1, 1)
+	check("Call-Info", &msg.PV.CallInfos, call, 3, 2)
+	if !msg.HL.PFlags.AllSet(HdrCallInfo, HdrAlertInfo) {
+		t.Errorf("HL.PFlags: Call-Info or Alert-Info not set: 0x%x",
+			msg.HL.PFlags)
+	}
+	// 3rd Call-Info value (not in Vals): appearance param
+	var iv PInfoBody
+	b := []byte("<sip:alice@example.com>;appearance-index=1;appearance=2\r\n\r\n")
+	if _, err := ParseOneInfo(HdrCallInfo, b, 0, &iv); err != 0 ||
+		string(iv.Appearance.Get(b)) != "2" {
+		t.Errorf("ParseOneInfo(%q) = %d (%q), appearance %q",
+			b, err, err, iv.Appearance.Get(b))
+	}
+}
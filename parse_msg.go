@@ -64,6 +64,77 @@ func (m *PSIPMsg) Init(msg []byte, hdrs []Hdr, contacts []PFromBody) {
 	}
 }
 
+// Clone copies the message m, together with all the parsed values, into
+// dst, using dstBuf for the message data (all the parsed values are
+// offsets in the message buffer, so they will remain valid).
+// If dstBuf is nil, a new buffer will be allocated.
+// dst parsed headers and contacts space (see Init()) are kept, or if not
+// initialized the default private arrays are used.
+// m must be fully parsed (see Parsed()): for a message still in progress
+// m.Buf might not point to the current message data yet.
+// It returns false if m is not fully parsed, if cap(dstBuf) is smaller
+// then len(m.Buf) or if the dst headers or contacts space is smaller then
+// the number of parsed headers or contacts in m.
+// If m has more headers than its headers space (see HeadersTruncated()),
+// the missing headers are re-parsed into the extra dst headers space (if
+// any), otherwise dst will remain truncated too. If m has more contacts
+// than its contacts space (see PContacts.More()), dst contacts space will
+// be limited to the copied contacts, so that dst will also report the
+// dropped ones.
+// Clone can be used for passing a parsed message to another goroutine
+// (without re-parsing it), while re-using the original buffer.
+func (m *PSIPMsg) Clone(dst *PSIPMsg, dstBuf []byte) bool {
+	if dst == m || !m.Parsed() {
+		return false
+	}
+	if dstBuf == nil {
+		dstBuf = make([]byte, len(m.Buf))
+	} else if cap(dstBuf) < len(m.Buf) {
+		return false
+	}
+	dstBuf = dstBuf[:len(m.Buf)]
+	hdrs := dst.HL.Hdrs
+	if hdrs == nil {
+		hdrs = dst.hdrs[:]
+	}
+	cvals := dst.PV.Contacts.Vals
+	if cvals == nil {
+		cvals = dst.contacts[:]
+	}
+	hno := m.HL.N
+	if hno > len(m.HL.Hdrs) {
+		hno = len(m.HL.Hdrs)
+	}
+	cno := m.PV.Contacts.VNo()
+	if len(hdrs) < hno || len(cvals) < cno {
+		return false
+	}
+	copy(dstBuf, m.Buf)
+	*dst = *m
+	dst.Buf = dstBuf
+	if m.RawMsg != nil {
+		// RawMsg is a slice of Buf
+		start := cap(m.Buf) - cap(m.RawMsg)
+		dst.RawMsg = dstBuf[start : start+len(m.RawMsg)]
+	}
+	copy(hdrs, m.HL.Hdrs[:hno])
+	dst.HL.Hdrs = hdrs
+	if m.HL.Truncated() {
+		// keep HL.N and dst truncated, but try to fill the extra space
+		dst.HL.Hdrs = hdrs[:hno]
+		if len(hdrs) > hno {
+			dst.GrowHdrs(hdrs)
+		}
+	}
+	copy(cvals, m.PV.Contacts.Vals[:cno])
+	dst.PV.Contacts.Vals = cvals
+	if m.PV.Contacts.More() {
+		// dropped contacts cannot be recovered, keep Contacts.N
+		dst.PV.Contacts.Vals = cvals[:cno]
+	}
+	return true
+}
+
 // Parsed returns true if the message if fully parsed
 // (and no more input is needed).
 func (m *PSIPMsg) Parsed() bool {
@@ -80,8 +151,13 @@ func (m *PSIPMsg) Request() bool {
 	return m.FL.Request()
 }
 
-// Method returns the numeric SIP method.
-// If the message is a reply, the method from the CSeq header will be used.
+// Method returns the numeric SIP method of the transaction the message
+// belongs to.
+// For requests it returns the request method from the first line
+// (FL.MethodNo) and for replies the method from the CSeq header
+// (PV.CSeq.MethodNo), e.g. MInvite for a 200 reply to an INVITE.
+// If the message is a reply and the CSeq header was not parsed (missing
+// or parsing not finished), it returns MUndef.
 func (m *PSIPMsg) Method() SIPMethod {
 
 	if m.Request() {
@@ -90,6 +166,121 @@ func (m *PSIPMsg) Method() SIPMethod {
 	return m.PV.CSeq.MethodNo
 }
 
+// RegFetch returns true if the message is a REGISTER request without
+// any Contact header, which according to RFC3261 10.2.3 is a query for the
+// current bindings and not a registration or de-registration.
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) RegFetch() bool {
+	return m.Request() && m.FL.MethodNo == MRegister &&
+		!m.HL.PFlags.Test(HdrContact)
+}
+
+// Software returns the value of the header identifying the software used
+// by the message sender: User-Agent for requests and Server for replies
+// (with fallback to User-Agent if no Server header is present).
+// It returns nil if no such header was found.
+func (m *PSIPMsg) Software() []byte {
+	if !m.Request() && m.HL.PFlags.Test(HdrServer) {
+		return m.HL.GetHdr(HdrServer).Val.Get(m.Buf)
+	}
+	if m.HL.PFlags.Test(HdrUA) {
+		return m.HL.GetHdr(HdrUA).Val.Get(m.Buf)
+	}
+	return nil
+}
+
+// TrackableError checks if the message contains everything needed for
+// call tracking and returns 0 (ErrHdrOk) if it does or the reason why it
+// cannot be tracked: ErrHdrNoCallID, ErrHdrNoCSeq, ErrHdrNoFrom,
+// ErrHdrNoTo, ErrHdrBadCSeq (the CSeq method does not match the request
+// method) or ErrHdrNoFromTag (in-dialog message, with a To tag, but
+// without a From tag).
+// The message must be parsed at least up to the end of the headers.
+func (m *PSIPMsg) TrackableError() ErrorHdr {
+	switch {
+	case !m.PV.Callid.Parsed() || m.PV.Callid.CallID.Empty():
+		return ErrHdrNoCallID
+	case !m.PV.CSeq.Parsed():
+		return ErrHdrNoCSeq
+	case !m.PV.From.Parsed():
+		return ErrHdrNoFrom
+	case !m.PV.To.Parsed():
+		return ErrHdrNoTo
+	}
+	if m.Request() && (m.FL.MethodNo != m.PV.CSeq.MethodNo ||
+		!m.FL.Method.Eq(m.Buf, m.PV.CSeq.Method, m.Buf)) {
+		return ErrHdrBadCSeq
+	}
+	if !m.PV.To.Tag.Empty() && m.PV.From.Tag.Empty() {
+		return ErrHdrNoFromTag
+	}
+	return ErrHdrOk
+}
+
+// HeadersTruncated returns true if the message has more headers than
+// the space available in HL.Hdrs, in which case the extra headers are
+// counted (HL.N) but not available (see GrowHdrs()).
+// Note that the values of the known headers (PV) are parsed even if the
+// headers themselves did not fit.
+func (m *PSIPMsg) HeadersTruncated() bool {
+	return m.HL.Truncated()
+}
+
+// GrowHdrs replaces the message headers space (HL.Hdrs) with hdrs, which
+// should be bigger, keeping the already parsed headers and adding the
+// headers that did not fit before (see HeadersTruncated()).
+// Only the header lines are re-parsed, the header values (PV) are not
+// touched.
+// It can be called only after the message was fully parsed (Buf is set
+// to the parsed message only at the end of ParseSIPMsg()) or after
+// ParseSIPMsg() returned ErrHdrNoCLen.
+// It returns ErrHdrOk on success, ErrHdrMoreBytes if the message is not
+// fully parsed yet, ErrHdrTrunc if hdrs is still too small (in which case
+// hdrs is used and filled as much as possible) or ErrHdrBug if the
+// headers cannot be re-parsed.
+func (m *PSIPMsg) GrowHdrs(hdrs []Hdr) ErrorHdr {
+	switch m.state {
+	case SIPMsgNoCLen, SIPMsgFIN:
+	default:
+		return ErrHdrMoreBytes
+	}
+	k := copy(hdrs, m.HL.Hdrs) // already parsed headers
+	old := m.HL.Hdrs
+	m.HL.Hdrs = hdrs
+	if k < len(old) || k >= m.HL.N {
+		if m.HL.Truncated() {
+			return ErrHdrTrunc
+		}
+		return ErrHdrOk
+	}
+	// find the start of the headers
+	var i int
+	if k > 0 {
+		i = int(hdrs[0].Name.Offs)
+	} else {
+		var fl PFLine
+		var err ErrorHdr
+		if i, err = ParseFLine(m.Buf, m.offs, &fl); err != 0 {
+			return ErrHdrBug
+		}
+	}
+	for n := 0; n < m.HL.N && n < len(hdrs); n++ {
+		var h Hdr
+		next, err := ParseHdrLine(m.Buf, i, &h, nil)
+		if err != 0 {
+			return ErrHdrBug
+		}
+		if n >= k {
+			hdrs[n] = h
+		}
+		i = next
+	}
+	if m.HL.Truncated() {
+		return ErrHdrTrunc
+	}
+	return ErrHdrOk
+}
+
 // SIPMsgIState holds the internal parsing state.
 type SIPMsgIState struct {
 	state uint8
@@ -109,9 +300,11 @@ const (
 
 // Parsing flags for ParseSIPMsg().
 const (
-	SIPMsgSkipBodyF   = 1 << iota // don't parse the body (return offset = body start)
-	SIPMsgCLenReqF                // error if SIPMsgSkipBodyF and no CLen
-	SIPMsgNoMoreDataF             // no more message data, stop at end of buf
+	SIPMsgSkipBodyF      = 1 << iota // don't parse the body (return offset = body start)
+	SIPMsgCLenReqF                   // error if SIPMsgSkipBodyF and no CLen
+	SIPMsgNoMoreDataF                // no more message data, stop at end of buf
+	SIPMsgSkipLeadingWSF             // skip leading CRLFs & whitespace
+	SIPMsgStrictCLenF                // error on Content-Length mismatch
 )
 
 // ParseSIPMsg parses a SIP message contained in buf[], starting
@@ -124,6 +317,18 @@ const (
 // It returns the offset at which parsing finished and an error.
 // If no more input data is available (buf contains everything, e.g. a full UDP
 // received packet) pass the SIPMsgNoMoreDataF flag.
+// If the SIPMsgSkipLeadingWSF flag is set, any CR, LF or whitespace before
+// the first line will be skipped (e.g. double CRLF keepalives sent before
+// the message) and the message will be considered to start at the first
+// non-whitespace character (msg.RawMsg will not include the skipped part).
+// If in this case the whole input contains only whitespace (or it is
+// empty, e.g. a 0-length UDP keepalive) and SIPMsgNoMoreDataF is also set,
+// ErrHdrKeepAlive will be returned.
+// If SIPMsgStrictCLenF and SIPMsgNoMoreDataF are set, ErrHdrBadCLen will be
+// returned if the Content-Length value is greater than the remaining data
+// (instead of accepting a truncated body) or if there is more data after the
+// body that is not whitespace (CRLF), e.g. a shorter Content-Length or
+// a concatenated message.
 // Note that a reference to buf[] will be "saved" inside msg.Buf when
 // parsing is complete.
 func ParseSIPMsg(buf []byte, offs int, msg *PSIPMsg, flags uint8) (int, ErrorHdr) {
@@ -132,7 +337,19 @@ func ParseSIPMsg(buf []byte, offs int, msg *PSIPMsg, flags uint8) (int, ErrorHdr
 	var err ErrorHdr
 	switch msg.state {
 	case SIPMsgInit:
-		msg.offs = offs
+		if (flags & SIPMsgSkipLeadingWSF) != 0 {
+			for ; o < len(buf) && (buf[o] == '\r' || buf[o] == '\n' ||
+				buf[o] == ' ' || buf[o] == '\t'); o++ {
+			}
+			if o >= len(buf) {
+				// only whitespace
+				if (flags & SIPMsgNoMoreDataF) != 0 {
+					return o, ErrHdrKeepAlive
+				}
+				return o, ErrHdrMoreBytes
+			}
+		}
+		msg.offs = o
 		msg.state = SIPMsgFLine
 		fallthrough
 	case SIPMsgFLine:
@@ -163,6 +380,10 @@ func ParseSIPMsg(buf []byte, offs int, msg *PSIPMsg, flags uint8) (int, ErrorHdr
 			// skip  msg.PV.CLen.Len bytes
 			if (o + int(msg.PV.CLen.UIVal)) > len(buf) {
 				if (flags & SIPMsgNoMoreDataF) != 0 {
+					if (flags & SIPMsgStrictCLenF) != 0 {
+						err = ErrHdrBadCLen
+						goto errBody
+					}
 					// allow truncated body
 					o = len(buf)
 					goto end
@@ -170,6 +391,18 @@ func ParseSIPMsg(buf []byte, offs int, msg *PSIPMsg, flags uint8) (int, ErrorHdr
 				// keep start-of-body offset (we use it on success/fully body)
 				return o, ErrHdrMoreBytes
 			}
+			if (flags & (SIPMsgStrictCLenF | SIPMsgNoMoreDataF)) ==
+				(SIPMsgStrictCLenF | SIPMsgNoMoreDataF) {
+				// check if something else besides whitespace follows
+				i := o + int(msg.PV.CLen.UIVal)
+				for ; i < len(buf) && (buf[i] == '\r' || buf[i] == '\n' ||
+					buf[i] == ' ' || buf[i] == '\t'); i++ {
+				}
+				if i < len(buf) {
+					err = ErrHdrBadCLen
+					goto errBody
+				}
+			}
 			o += int(msg.PV.CLen.UIVal)
 		} else {
 			if (flags & SIPMsgCLenReqF) != 0 {
@@ -191,6 +424,7 @@ end:
 	return o, 0
 errFL:
 errHL:
+errBody:
 errBUG:
 	if err != ErrHdrMoreBytes {
 		msg.state = SIPMsgErr
@@ -200,3 +434,50 @@ errBUG:
 	}
 	return o, err
 }
+
+// ParsedMsg is a self-contained parsed SIP message (it owns its message
+// buffer and parsed headers and contacts space), see ParseMessage().
+type ParsedMsg struct {
+	PSIPMsg
+}
+
+// ParseMessage is a convenience function that parses the complete SIP
+// message contained in buf and returns the result (which does not
+// reference buf, the message is copied).
+// It allocates space for all the message headers and contacts, so all of
+// them will be available in the returned ParsedMsg.
+// Only the first message in buf is parsed (leading whitespace is skipped),
+// RawMsg will contain the actual parsed message.
+// On error it returns nil and an ErrorHdr converted to error
+// (see ErrorHdr.ErrorConv()).
+// Note that ParseMessage allocates memory for each message and it is not
+// intended for high performance use (for that use ParseSIPMsg() with
+// a re-used PSIPMsg).
+func ParseMessage(buf []byte) (*ParsedMsg, error) {
+	const flags = SIPMsgSkipLeadingWSF | SIPMsgNoMoreDataF
+	pm := &ParsedMsg{}
+	mbuf := make([]byte, len(buf))
+	copy(mbuf, buf)
+	pm.Init(mbuf, nil, nil)
+	_, err := ParseSIPMsg(mbuf, 0, &pm.PSIPMsg, flags)
+	if err != 0 {
+		return nil, err.ErrorConv()
+	}
+	if pm.HL.N > len(pm.HL.Hdrs) ||
+		pm.PV.Contacts.N > len(pm.PV.Contacts.Vals) {
+		// not enough space, re-parse with the right sizes (known now)
+		hdrs := pm.HL.Hdrs
+		if pm.HL.N > len(hdrs) {
+			hdrs = make([]Hdr, pm.HL.N)
+		}
+		contacts := pm.PV.Contacts.Vals
+		if pm.PV.Contacts.N > len(contacts) {
+			contacts = make([]PFromBody, pm.PV.Contacts.N)
+		}
+		pm.Init(mbuf, hdrs, contacts)
+		if _, err = ParseSIPMsg(mbuf, 0, &pm.PSIPMsg, flags); err != 0 {
+			return nil, err.ErrorConv()
+		}
+	}
+	return pm, nil
+}
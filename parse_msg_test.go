@@ -9,6 +9,7 @@ package sipsp
 import (
 	"bytes"
 	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -369,3 +370,728 @@ func testParseMsgPieces(t *testing.T, buf []byte, offs int, e *msgTest,
 	// parse the rest
 	testParseInitMsg(t, &msg, buf, o, e)
 }
+
+func TestHdrLstGetAll(t *testing.T) {
+	buf := unescapeCRLF(`INVITE sip:x@y.com SIP/2.0\r
+From: <sip:a@foo.bar>;tag=1234\r
+To: <sip:x@y.com>\r
+X-Foo: 1\r
+Call-ID: a84b4c76e66710\r
+x-foo: 2\r
+CSeq: 314159 INVITE\r
+f: <sip:b@foo.bar>;tag=5678\r
+X-Bar: 3\r
+X-FOO: 4\r
+Content-Length: 0\r
+\r
+`)
+	type testCase struct {
+		name string
+		hdrs int      // size of the headers slice
+		vals []string // expected header values
+		err  ErrorHdr // expected error
+	}
+	tests := [...]testCase{
+		{"X-Foo", 12, []string{"1", "2", "4"}, ErrHdrOk},
+		{"x-bar", 12, []string{"3"}, ErrHdrOk},
+		{"X-Baz", 12, []string{}, ErrHdrOk},
+		{"From", 12, []string{"<sip:a@foo.bar>;tag=1234",
+			"<sip:b@foo.bar>;tag=5678"}, ErrHdrOk},
+		{"X-Foo", 6, []string{"1", "2"}, ErrHdrTrunc},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		msg.Init(buf, make([]Hdr, tc.hdrs), nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		var vals []string
+		err := msg.HL.GetAll(msg.Buf, []byte(tc.name), func(h *Hdr) bool {
+			vals = append(vals, string(h.Val.Get(msg.Buf)))
+			return true
+		})
+		if err != tc.err {
+			t.Errorf("GetAll(%q) returned error %d (%q), expected %d (%q)",
+				tc.name, err, err, tc.err, tc.err)
+		}
+		if len(vals) != len(tc.vals) {
+			t.Errorf("GetAll(%q) returned %d values (%q), expected %d (%q)",
+				tc.name, len(vals), vals, len(tc.vals), tc.vals)
+			continue
+		}
+		for i := range vals {
+			if vals[i] != tc.vals[i] {
+				t.Errorf("GetAll(%q) value %d: %q, expected %q",
+					tc.name, i, vals[i], tc.vals[i])
+			}
+		}
+	}
+}
+
+func TestParseMsgSkipLeadingWS(t *testing.T) {
+	m := unescapeCRLF(`OPTIONS sip:x@y.com SIP/2.0\r
+From: <sip:a@foo.bar>;tag=1234\r
+To: <sip:x@y.com>\r
+Call-ID: a84b4c76e66710\r
+CSeq: 1 OPTIONS\r
+Content-Length: 4\r
+\r
+body`)
+	type testCase struct {
+		prefix string   // leading junk
+		flags  uint8    // parsing flags
+		err    ErrorHdr // expected error
+	}
+	tests := [...]testCase{
+		{"", SIPMsgSkipLeadingWSF, 0},
+		{"\r\n\r\n", SIPMsgSkipLeadingWSF, 0},
+		{"\r\n\r\n", SIPMsgSkipLeadingWSF | SIPMsgNoMoreDataF, 0},
+		{" \t\n\r\n", SIPMsgSkipLeadingWSF, 0},
+		{"\r\n\r\n", SIPMsgNoMoreDataF, ErrHdrBadFLine},
+	}
+	for _, tc := range tests {
+		buf := append([]byte(tc.prefix), m...)
+		var msg PSIPMsg
+		msg.Init(buf, nil, nil)
+		o, err := ParseSIPMsg(buf, 0, &msg, tc.flags)
+		if err != tc.err {
+			t.Errorf("ParseSIPMsg(%q, 0x%x) = [%d, %d(%q)], expected %d(%q)",
+				buf, tc.flags, o, err, err, tc.err, tc.err)
+			continue
+		}
+		if err != 0 {
+			continue
+		}
+		if o != len(buf) || !bytes.Equal(msg.RawMsg, m) ||
+			string(msg.Body.Get(msg.Buf)) != "body" {
+			t.Errorf("ParseSIPMsg(%q, 0x%x) = [%d] raw %q body %q",
+				buf, tc.flags, o, msg.RawMsg, msg.Body.Get(msg.Buf))
+		}
+		if string(msg.FL.Method.Get(msg.Buf)) != "OPTIONS" {
+			t.Errorf("ParseSIPMsg(%q, 0x%x): bad method %q",
+				buf, tc.flags, msg.FL.Method.Get(msg.Buf))
+		}
+	}
+
+	// keepalive only
+	keepalives := [...]string{"\r\n\r\n", "\r\n", "\n", "  \r\n", ""}
+	for _, k := range keepalives {
+		var msg PSIPMsg
+		buf := []byte(k)
+		msg.Init(buf, nil, nil)
+		o, err := ParseSIPMsg(buf, 0, &msg,
+			SIPMsgSkipLeadingWSF|SIPMsgNoMoreDataF)
+		if err != ErrHdrKeepAlive || o != len(buf) {
+			t.Errorf("ParseSIPMsg(%q) = [%d, %d(%q)], expected keepalive",
+				buf, o, err, err)
+		}
+		// stream mode, more data can follow
+		msg.Init(buf, nil, nil)
+		o, err = ParseSIPMsg(buf, 0, &msg, SIPMsgSkipLeadingWSF)
+		if err != ErrHdrMoreBytes || o != len(buf) {
+			t.Errorf("ParseSIPMsg(%q) = [%d, %d(%q)], expected more bytes",
+				buf, o, err, err)
+		}
+		// continue with the real message
+		buf = append(buf, m...)
+		o, err = ParseSIPMsg(buf, o, &msg, SIPMsgSkipLeadingWSF)
+		if err != 0 || o != len(buf) || !bytes.Equal(msg.RawMsg, m) {
+			t.Errorf("ParseSIPMsg(%q) = [%d, %d(%q)], raw %q",
+				buf, o, err, err, msg.RawMsg)
+		}
+	}
+}
+
+func TestPSIPMsgMethod(t *testing.T) {
+	type testCase struct {
+		m   string    // message
+		req bool      // expected Request() result
+		mth SIPMethod // expected Method() result
+	}
+	tests := [...]testCase{
+		{m: `INVITE sip:x@y.com SIP/2.0\r
+CSeq: 1 INVITE\r
+\r
+`, req: true, mth: MInvite},
+		{m: `SIP/2.0 200 OK\r
+CSeq: 1 INVITE\r
+\r
+`, req: false, mth: MInvite},
+		{m: `SIP/2.0 200 OK\r
+CSeq: 2 REGISTER\r
+\r
+`, req: false, mth: MRegister},
+		{m: `SIP/2.0 487 Request Terminated\r
+CSeq: 3 INVITE\r
+\r
+`, req: false, mth: MInvite},
+		{m: `SIP/2.0 200 OK\r
+CSeq: 3 CANCEL\r
+\r
+`, req: false, mth: MCancel},
+		{m: `ACK sip:x@y.com SIP/2.0\r
+CSeq: 1 ACK\r
+\r
+`, req: true, mth: MAck},
+		{m: `SIP/2.0 200 OK\r
+Call-ID: a84b4c76e66710\r
+\r
+`, req: false, mth: MUndef}, // no CSeq
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if msg.Request() != tc.req {
+			t.Errorf("Request() = %v for %q, expected %v",
+				msg.Request(), buf, tc.req)
+		}
+		if msg.Method() != tc.mth {
+			t.Errorf("Method() = %s for %q, expected %s",
+				msg.Method(), buf, tc.mth)
+		}
+	}
+}
+
+func TestEffectiveExpires(t *testing.T) {
+	type testCase struct {
+		m    string   // message
+		exp  []uint32 // expected expires for each contact
+		hexp uint32   // expected value for a nil contact
+	}
+	tests := [...]testCase{
+		{m: `REGISTER sip:foo.bar SIP/2.0\r
+Contact: <sip:a@1.2.3.4>;expires=60, <sip:b@1.2.3.4>\r
+Expires: 300\r
+\r
+`, exp: []uint32{60, 300}, hexp: 300},
+		{m: `REGISTER sip:foo.bar SIP/2.0\r
+Contact: <sip:a@1.2.3.4>;expires=0, <sip:b@1.2.3.4>\r
+\r
+`, exp: []uint32{0, DefaultRegExpires}, hexp: DefaultRegExpires},
+		{m: `REGISTER sip:foo.bar SIP/2.0\r
+Expires: 0\r
+Contact: <sip:a@1.2.3.4>\r
+\r
+`, exp: []uint32{0}, hexp: 0},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if msg.PV.Contacts.VNo() != len(tc.exp) {
+			t.Fatalf("%q: %d contacts parsed, expected %d",
+				buf, msg.PV.Contacts.VNo(), len(tc.exp))
+		}
+		for i, e := range tc.exp {
+			c := msg.PV.Contacts.GetContact(i)
+			if r := msg.PV.EffectiveExpires(c, DefaultRegExpires); r != e {
+				t.Errorf("%q: EffectiveExpires(contact %d) = %d, expected %d",
+					buf, i, r, e)
+			}
+		}
+		if r := msg.PV.EffectiveExpires(nil, DefaultRegExpires); r != tc.hexp {
+			t.Errorf("%q: EffectiveExpires(nil) = %d, expected %d",
+				buf, r, tc.hexp)
+		}
+		hasExp := bytes.Contains(buf, []byte("Expires:"))
+		if v, ok := msg.PV.ExpiresVal(); ok != hasExp ||
+			(ok && v != tc.hexp) {
+			t.Errorf("%q: ExpiresVal() = %d, %v", buf, v, ok)
+		}
+	}
+}
+
+func TestParseMsgStrictCLen(t *testing.T) {
+	type testCase struct {
+		m     string
+		flags uint8
+		err   ErrorHdr
+		body  string
+	}
+	const hdrs = `MESSAGE sip:bob@biloxi.com SIP/2.0\r
+CSeq: 1 MESSAGE\r
+`
+	tests := [...]testCase{
+		{hdrs + "Content-Length: 4\r\n\r\ntest", SIPMsgStrictCLenF, 0, "test"},
+		{hdrs + "Content-Length: 4\r\n\r\ntest\r\n\r\n", SIPMsgStrictCLenF,
+			0, "test"},
+		// bigger Content-Length
+		{hdrs + "Content-Length: 10\r\n\r\ntest", 0, 0, "test"},
+		{hdrs + "Content-Length: 10\r\n\r\ntest", SIPMsgStrictCLenF,
+			ErrHdrBadCLen, ""},
+		// smaller Content-Length
+		{hdrs + "Content-Length: 2\r\n\r\ntest", 0, 0, "te"},
+		{hdrs + "Content-Length: 2\r\n\r\ntest", SIPMsgStrictCLenF,
+			ErrHdrBadCLen, ""},
+		// concatenated message
+		{hdrs + "Content-Length: 0\r\n\r\n" + hdrs + "\r\n", SIPMsgStrictCLenF,
+			ErrHdrBadCLen, ""},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		_, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF|tc.flags)
+		if err != tc.err {
+			t.Errorf("ParseSIPMsg(%q, 0x%x) = %d (%q) expected %d (%q)",
+				buf, tc.flags, err, err, tc.err, tc.err)
+			continue
+		}
+		if err == 0 && string(msg.Body.Get(msg.Buf)) != tc.body {
+			t.Errorf("ParseSIPMsg(%q, 0x%x): body %q expected %q",
+				buf, tc.flags, msg.Body.Get(msg.Buf), tc.body)
+		}
+		if err != 0 && !msg.Err() {
+			t.Errorf("ParseSIPMsg(%q, 0x%x): error %q but msg not in"+
+				" error state", buf, tc.flags, err)
+		}
+	}
+	// without SIPMsgNoMoreDataF more bytes are requested
+	var msg PSIPMsg
+	buf := unescapeCRLF(hdrs + "Content-Length: 10\r\n\r\ntest")
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgStrictCLenF); err != ErrHdrMoreBytes {
+		t.Errorf("ParseSIPMsg(%q) = %d (%q) expected %q",
+			buf, err, err, ErrHdrMoreBytes)
+	}
+}
+
+func TestPSIPMsgClone(t *testing.T) {
+	m := unescapeCRLF(`
+
+REGISTER sip:registrar.biloxi.com SIP/2.0\r
+Via: SIP/2.0/UDP bobspc.biloxi.com:5060;branch=z9hG4bKnashds7\r
+To: Bob <sip:bob@biloxi.com>\r
+From: Bob <sip:bob@biloxi.com>;tag=456248\r
+Call-ID: 843817637684230@998sdasdh09\r
+CSeq: 1826 REGISTER\r
+Contact: <sip:bob@192.0.2.4>;expires=60, <sip:bob@192.0.2.5>\r
+Contact: <sip:bob@192.0.2.6>\r
+Content-Length: 4\r
+\r
+body`)
+	var msg PSIPMsg
+	msg.Init(m, nil, nil)
+	if _, err := ParseSIPMsg(m, 0, &msg,
+		SIPMsgSkipLeadingWSF|SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", m, err, err)
+	}
+	var dst PSIPMsg
+	if !msg.Clone(&dst, nil) {
+		t.Fatalf("Clone failed")
+	}
+	// overwrite the original buffer
+	for i := range m {
+		m[i] = 'x'
+	}
+	if &dst.Buf[0] == &msg.Buf[0] || &dst.HL.Hdrs[0] == &msg.HL.Hdrs[0] ||
+		&dst.PV.Contacts.Vals[0] == &msg.PV.Contacts.Vals[0] {
+		t.Errorf("Clone: cloned message shares data with the original")
+	}
+	checks := [...]struct {
+		name     string
+		val, exp []byte
+	}{
+		{"from uri", dst.PV.From.URI.Get(dst.Buf), []byte("sip:bob@biloxi.com")},
+		{"from tag", dst.PV.From.Tag.Get(dst.Buf), []byte("456248")},
+		{"to name", dst.PV.To.Name.Get(dst.Buf), []byte("Bob ")},
+		{"callid", dst.PV.Callid.CallID.Get(dst.Buf),
+			[]byte("843817637684230@998sdasdh09")},
+		{"contact0", dst.PV.Contacts.Vals[0].URI.Get(dst.Buf),
+			[]byte("sip:bob@192.0.2.4")},
+		{"contact1", dst.PV.Contacts.Vals[1].URI.Get(dst.Buf),
+			[]byte("sip:bob@192.0.2.5")},
+		{"contact2", dst.PV.Contacts.Vals[2].URI.Get(dst.Buf),
+			[]byte("sip:bob@192.0.2.6")},
+		{"cseq method", dst.PV.CSeq.Method.Get(dst.Buf), []byte("REGISTER")},
+		{"via hdr", dst.HL.GetHdr(HdrVia).Val.Get(dst.Buf),
+			[]byte("SIP/2.0/UDP bobspc.biloxi.com:5060;branch=z9hG4bKnashds7")},
+		{"body", dst.Body.Get(dst.Buf), []byte("body")},
+		{"raw msg start", dst.RawMsg[:8], []byte("REGISTER")},
+	}
+	for _, c := range checks {
+		if !bytes.Equal(c.val, c.exp) {
+			t.Errorf("Clone: %s = %q expected %q", c.name, c.val, c.exp)
+		}
+	}
+	if dst.PV.Contacts.N != 3 || dst.PV.Contacts.Vals[0].Expires != 60 ||
+		dst.HL.N != msg.HL.N || !dst.Parsed() {
+		t.Errorf("Clone: bad parsed values: contacts %d expires %d hdrs %d",
+			dst.PV.Contacts.N, dst.PV.Contacts.Vals[0].Expires, dst.HL.N)
+	}
+	// not enough space
+	var small PSIPMsg
+	small.Init(nil, make([]Hdr, 2), nil)
+	if msg.Clone(&small, nil) {
+		t.Errorf("Clone: expected failure for a too small headers space")
+	}
+	if msg.Clone(&dst, make([]byte, 0, len(m)-1)) {
+		t.Errorf("Clone: expected failure for a too small buffer")
+	}
+	// message not fully parsed
+	pbuf := append([]byte(nil), dst.Buf...) // m was overwritten
+	var partial PSIPMsg
+	partial.Init(pbuf, nil, nil)
+	if _, err := ParseSIPMsg(pbuf[:len(pbuf)-2], 0, &partial,
+		SIPMsgSkipLeadingWSF); err != ErrHdrMoreBytes ||
+		partial.state != SIPMsgBody {
+		t.Fatalf("ParseSIPMsg(partial) = %d (%q)", err, err)
+	}
+	if partial.Clone(&dst, nil) {
+		t.Errorf("Clone: expected failure for a partially parsed message")
+	}
+}
+
+// GrowHdrs() must wait for the end of the message, m.Buf is updated only
+// when parsing ends.
+func TestPSIPMsgGrowHdrsPartialBody(t *testing.T) {
+	buf := []byte("INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+		"From: <sip:alice@atlanta.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@biloxi.com>\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Content-Length: 4\r\n\r\nbody")
+	var msg PSIPMsg
+	msg.Init(buf[:10], make([]Hdr, 2), nil)
+	o, err := ParseSIPMsg(buf[:10], 0, &msg, 0)
+	if err != ErrHdrMoreBytes {
+		t.Fatalf("ParseSIPMsg(%q) = %d (%q)", buf[:10], err, err)
+	}
+	// headers complete, partial body
+	end := len(buf) - 2
+	if o, err = ParseSIPMsg(buf[:end], o, &msg, 0); err != ErrHdrMoreBytes {
+		t.Fatalf("ParseSIPMsg(%q) = %d (%q)", buf[:end], err, err)
+	}
+	if err := msg.GrowHdrs(make([]Hdr, 8)); err != ErrHdrMoreBytes {
+		t.Errorf("GrowHdrs() on a partial body = %d (%q)", err, err)
+	}
+	if _, err = ParseSIPMsg(buf, o, &msg, 0); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) = %d (%q)", buf, err, err)
+	}
+	if err := msg.GrowHdrs(make([]Hdr, 8)); err != 0 ||
+		msg.HeadersTruncated() {
+		t.Fatalf("GrowHdrs() = %d (%q)", err, err)
+	}
+	names := [...]string{"From", "To", "Call-ID", "CSeq", "Content-Length"}
+	for i, n := range names {
+		if string(msg.HL.Hdrs[i].Name.Get(msg.Buf)) != n {
+			t.Errorf("header %d: %q expected %q", i,
+				msg.HL.Hdrs[i].Name.Get(msg.Buf), n)
+		}
+	}
+}
+
+func TestPSIPMsgCloneTruncated(t *testing.T) {
+	buf := []byte("REGISTER sip:registrar.biloxi.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP bobspc.biloxi.com:5060;branch=z9hG4bKnashds7\r\n" +
+		"To: Bob <sip:bob@biloxi.com>\r\n" +
+		"From: Bob <sip:bob@biloxi.com>;tag=456248\r\n" +
+		"Call-ID: 843817637684230@998sdasdh09\r\n" +
+		"CSeq: 1826 REGISTER\r\n" +
+		"Contact: <sip:bob@192.0.2.4>, <sip:bob@192.0.2.5>\r\n" +
+		"Contact: <sip:bob@192.0.2.6>\r\n\r\n")
+	names := [...]string{"Via", "To", "From", "Call-ID", "CSeq", "Contact",
+		"Contact"}
+	var msg PSIPMsg
+	msg.Init(buf, make([]Hdr, 3), make([]PFromBody, 1))
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+	if !msg.HeadersTruncated() || !msg.PV.Contacts.More() {
+		t.Fatalf("message not truncated: %d/%d headers, %d/%d contacts",
+			len(msg.HL.Hdrs), msg.HL.N, msg.PV.Contacts.VNo(),
+			msg.PV.Contacts.N)
+	}
+	for _, size := range [...]int{3, 5, 7, 10} {
+		var dst PSIPMsg
+		dst.Init(nil, make([]Hdr, size), make([]PFromBody, 4))
+		if !msg.Clone(&dst, nil) {
+			t.Fatalf("size %d: Clone failed", size)
+		}
+		if dst.HL.N != msg.HL.N || dst.PV.Contacts.N != msg.PV.Contacts.N {
+			t.Errorf("size %d: cloned counts: %d headers, %d contacts",
+				size, dst.HL.N, dst.PV.Contacts.N)
+		}
+		if dst.HeadersTruncated() != (size < len(names)) {
+			t.Errorf("size %d: HeadersTruncated() = %v", size,
+				dst.HeadersTruncated())
+		}
+		// only the first contact was kept
+		if dst.PV.Contacts.VNo() != 1 || dst.PV.Contacts.Dropped() != 2 {
+			t.Errorf("size %d: cloned contacts: %d kept, %d dropped", size,
+				dst.PV.Contacts.VNo(), dst.PV.Contacts.Dropped())
+		}
+		n := size
+		if n > len(names) {
+			n = len(names)
+		}
+		if len(dst.HL.Hdrs) < n {
+			t.Fatalf("size %d: only %d headers", size, len(dst.HL.Hdrs))
+		}
+		for i := 0; i < n; i++ {
+			if string(dst.HL.Hdrs[i].Name.Get(dst.Buf)) != names[i] {
+				t.Errorf("size %d: header %d: %q expected %q", size, i,
+					dst.HL.Hdrs[i].Name.Get(dst.Buf), names[i])
+			}
+		}
+		if size < len(names) {
+			if err := dst.GrowHdrs(make([]Hdr, 8)); err != 0 ||
+				dst.HeadersTruncated() {
+				t.Errorf("size %d: GrowHdrs() on clone = %d (%q)",
+					size, err, err)
+			}
+		}
+	}
+}
+
+func TestPSIPMsgSoftware(t *testing.T) {
+	type testCase struct {
+		m  string
+		sw string
+	}
+	tests := [...]testCase{
+		{"INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 INVITE\r\nUser-Agent: UAC/1.0\r\n\r\n", "UAC/1.0"},
+		// Server in requests is ignored
+		{"INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 INVITE\r\nServer: UAS/2.0\r\n\r\n", ""},
+		{"SIP/2.0 200 OK\r\n" +
+			"CSeq: 1 INVITE\r\nServer: UAS/2.0\r\n" +
+			"User-Agent: UAC/1.0\r\n\r\n", "UAS/2.0"},
+		// fallback to User-Agent
+		{"SIP/2.0 200 OK\r\n" +
+			"CSeq: 1 INVITE\r\nUser-Agent: UAC/1.0\r\n\r\n", "UAC/1.0"},
+		{"SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\n\r\n", ""},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if sw := msg.Software(); string(sw) != tc.sw {
+			t.Errorf("Software(%q) = %q expected %q", buf, sw, tc.sw)
+		}
+	}
+}
+
+func TestPSIPMsgRegFetch(t *testing.T) {
+	type testCase struct {
+		m     string
+		fetch bool
+	}
+	tests := [...]testCase{
+		{"REGISTER sip:biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 REGISTER\r\n\r\n", true},
+		{"REGISTER sip:biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 REGISTER\r\nContact: <sip:bob@192.0.2.4>\r\n\r\n",
+			false},
+		{"REGISTER sip:biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 REGISTER\r\nContact: *\r\nExpires: 0\r\n\r\n", false},
+		// reply with the current bindings
+		{"SIP/2.0 200 OK\r\n" +
+			"CSeq: 1 REGISTER\r\n\r\n", false},
+		{"OPTIONS sip:biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1 OPTIONS\r\n\r\n", false},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if f := msg.RegFetch(); f != tc.fetch {
+			t.Errorf("RegFetch(%q) = %v expected %v", buf, f, tc.fetch)
+		}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	for _, c := range tests1 {
+		buf := unescapeCRLF(c.m)
+		buf = append(buf, '\r')
+		buf = append(buf, '\n')
+		if c.body != "" {
+			buf = append(buf, unescapeCRLF(c.body)...)
+		}
+		orig := append([]byte(nil), buf...)
+		pm, err := ParseMessage(buf)
+		if err != nil {
+			t.Errorf("ParseMessage(%q) failed: %s", buf, err)
+			continue
+		}
+		// make sure the result does not reference buf
+		for i := range buf {
+			buf[i] = 'x'
+		}
+		if !pm.Parsed() || !bytes.Equal(pm.RawMsg, orig) {
+			t.Errorf("ParseMessage(%q): bad raw message %q (parsed %v)",
+				orig, pm.RawMsg, pm.Parsed())
+		}
+		if pm.HL.N > len(pm.HL.Hdrs) ||
+			pm.PV.Contacts.N > len(pm.PV.Contacts.Vals) {
+			t.Errorf("ParseMessage(%q): not all headers (%d/%d) or"+
+				" contacts (%d/%d) available", orig,
+				pm.HL.N, len(pm.HL.Hdrs),
+				pm.PV.Contacts.N, len(pm.PV.Contacts.Vals))
+		}
+		if c.hf != 0 && pm.HL.PFlags != c.hf {
+			t.Errorf("ParseMessage(%q): header flags 0x%x expected 0x%x",
+				orig, pm.HL.PFlags, c.hf)
+		}
+		if c.n != 0 && pm.HL.N != c.n {
+			t.Errorf("ParseMessage(%q): %d headers expected %d",
+				orig, pm.HL.N, c.n)
+		}
+	}
+	// many headers and contacts
+	m := "REGISTER sip:biloxi.com SIP/2.0\r\nCSeq: 1 REGISTER\r\n" +
+		strings.Repeat("Contact: <sip:a@1.2.3.4>, <sip:b@1.2.3.4>\r\n", 15) +
+		"\r\n"
+	pm, err := ParseMessage([]byte(m))
+	if err != nil {
+		t.Fatalf("ParseMessage(%q) failed: %s", m, err)
+	}
+	if pm.HL.N != 16 || len(pm.HL.Hdrs) < 16 || pm.PV.Contacts.N != 30 ||
+		pm.PV.Contacts.VNo() != 30 {
+		t.Errorf("ParseMessage(%q): %d headers, %d/%d contacts", m,
+			pm.HL.N, pm.PV.Contacts.N, pm.PV.Contacts.VNo())
+	}
+	if _, err := ParseMessage([]byte("INVITE\r\n\r\n")); err == nil {
+		t.Errorf("ParseMessage: expected error for a bad message")
+	}
+}
+
+func TestPSIPMsgTrackableError(t *testing.T) {
+	const invite = "INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+		"From: <sip:alice@atlanta.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@biloxi.com>\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 314159 INVITE\r\n\r\n"
+	type testCase struct {
+		m   string
+		err ErrorHdr
+	}
+	tests := [...]testCase{
+		{invite, 0},
+		{strings.Replace(invite, "CSeq: 314159 INVITE\r\n", "", 1),
+			ErrHdrNoCSeq},
+		{strings.Replace(invite,
+			"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n", "", 1),
+			ErrHdrNoCallID},
+		{strings.Replace(invite, "From: <sip:alice@atlanta.com>;tag=1928301774\r\n",
+			"", 1), ErrHdrNoFrom},
+		{strings.Replace(invite, "To: <sip:bob@biloxi.com>\r\n", "", 1),
+			ErrHdrNoTo},
+		{strings.Replace(invite, "314159 INVITE", "314159 BYE", 1),
+			ErrHdrBadCSeq},
+		// initial request without From tag (RFC2543)
+		{strings.Replace(invite, ";tag=1928301774", "", 1), 0},
+		// in-dialog request without From tag
+		{strings.Replace(strings.Replace(invite, ";tag=1928301774", "", 1),
+			"<sip:bob@biloxi.com>", "<sip:bob@biloxi.com>;tag=a6c85cf", 1),
+			ErrHdrNoFromTag},
+		// reply
+		{strings.Replace(invite, "INVITE sip:bob@biloxi.com SIP/2.0",
+			"SIP/2.0 180 Ringing", 1), 0},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if err := msg.TrackableError(); err != tc.err {
+			t.Errorf("TrackableError(%q) = %d (%q) expected %d (%q)",
+				buf, err, err, tc.err, tc.err)
+		}
+	}
+}
+
+func TestPSIPMsgIntervalTooBrief(t *testing.T) {
+	const reply = "SIP/2.0 423 Interval Too Brief\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 1826 REGISTER\r\nMin-Expires: 3600\r\n\r\n"
+	type testCase struct {
+		m      string
+		minExp uint32
+		ok     bool
+	}
+	tests := [...]testCase{
+		{reply, 3600, true},
+		{strings.Replace(reply, "Min-Expires: 3600\r\n", "", 1), 0, false},
+		{strings.Replace(reply, "423 Interval Too Brief", "401 Unauthorized",
+			1), 0, false},
+		{"REGISTER sip:registrar.biloxi.com SIP/2.0\r\n" +
+			"CSeq: 1826 REGISTER\r\nMin-Expires: 3600\r\n\r\n", 0, false},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if v, ok := msg.IntervalTooBrief(); v != tc.minExp || ok != tc.ok {
+			t.Errorf("IntervalTooBrief(%q) = %d, %v expected %d, %v",
+				buf, v, ok, tc.minExp, tc.ok)
+		}
+	}
+}
+
+func TestPSIPMsgGrowHdrs(t *testing.T) {
+	buf := []byte("INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r\n" +
+		"From: <sip:alice@atlanta.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@biloxi.com>\r\n" +
+		"X-Foo: a,\r\n b\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 314159 INVITE\r\n\r\n")
+	names := [...]string{"Via", "From", "To", "X-Foo", "Call-ID", "CSeq"}
+	for _, size := range [...]int{0, 2, 6} {
+		var msg PSIPMsg
+		msg.Init(buf, make([]Hdr, size), nil)
+		if err := msg.GrowHdrs(make([]Hdr, 8)); err != ErrHdrMoreBytes {
+			t.Errorf("GrowHdrs() before parsing returned %d (%q)", err, err)
+		}
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if msg.HeadersTruncated() != (size < len(names)) {
+			t.Errorf("size %d: HeadersTruncated() = %v", size,
+				msg.HeadersTruncated())
+		}
+		// still too small
+		if size < len(names) {
+			if err := msg.GrowHdrs(make([]Hdr, size+1)); err != ErrHdrTrunc {
+				t.Errorf("size %d: GrowHdrs(%d) = %d (%q), expected trunc",
+					size, size+1, err, err)
+			}
+		}
+		if err := msg.GrowHdrs(make([]Hdr, 8)); err != 0 {
+			t.Fatalf("size %d: GrowHdrs() failed: %d (%q)", size, err, err)
+		}
+		if msg.HeadersTruncated() {
+			t.Errorf("size %d: HeadersTruncated() after GrowHdrs()", size)
+		}
+		for i, n := range names {
+			if string(msg.HL.Hdrs[i].Name.Get(buf)) != n {
+				t.Errorf("size %d: header %d: %q expected %q", size, i,
+					msg.HL.Hdrs[i].Name.Get(buf), n)
+			}
+		}
+		if v := msg.HL.Hdrs[3].Val.Get(buf); string(v) != "a,\r\n b" {
+			t.Errorf("size %d: X-Foo value %q", size, v)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"github.com/intuitivelabs/bytescase"
+)
+
+// PPCVBody contains a parsed P-Charging-Vector value (RFC7315), e.g.:
+// icid-value=1234bc9876e;icid-generated-at=192.0.6.8;orig-ioi=home1.net .
+type PPCVBody struct {
+	ICID      PField   // icid-value parameter value (quotes not removed)
+	ICIDGenAt PField   // icid-generated-at parameter value
+	OrigIOI   PField   // orig-ioi parameter value
+	TermIOI   PField   // term-ioi parameter value
+	Err       ErrorHdr // parsing error for a P-Charging-Vector header
+	done      bool     // a value was parsed, successfully or not (see Err)
+}
+
+// Reset re-initializes the parsed values.
+func (pcv *PPCVBody) Reset() {
+	*pcv = PPCVBody{}
+}
+
+// Parsed returns true if a value was successfully parsed.
+func (pcv *PPCVBody) Parsed() bool {
+	return pcv.done && pcv.Err == 0
+}
+
+// ParsePCVVal parses a complete P-Charging-Vector value, contained in
+// buf[offs:] (buf should end at the end of the value) and fills pcv.
+// Unknown parameters are ignored.
+// The returned error is also saved in pcv.Err.
+// It returns the offset after the parsed value and ErrHdrOk on success,
+// ErrHdrEmpty for an empty value or ErrHdrValBad if the mandatory
+// icid-value parameter is missing or a parameter cannot be parsed.
+func ParsePCVVal(buf []byte, offs int, pcv *PPCVBody) (int, ErrorHdr) {
+	n, err := parsePCVVal(buf, offs, pcv)
+	pcv.done = true
+	pcv.Err = err
+	return n, err
+}
+
+// parsePCVVal is the ParsePCVVal() internal version.
+func parsePCVVal(buf []byte, offs int, pcv *PPCVBody) (int, ErrorHdr) {
+	const flags = POptParamSemiSepF | POptInputEndF
+	var param PTokParam
+
+	i := skipWS(buf, offs)
+	if i >= len(buf) {
+		return i, ErrHdrEmpty
+	}
+	for {
+		next, err := ParseTokenParam(buf, i, &param, flags)
+		if err != 0 && err != ErrHdrMoreValues && err != ErrHdrEOH {
+			return next, ErrHdrValBad
+		}
+		switch name := param.Name.Get(buf); {
+		case bytescase.CmpEq(name, []byte("icid-value")):
+			pcv.ICID = param.Val
+		case bytescase.CmpEq(name, []byte("icid-generated-at")):
+			pcv.ICIDGenAt = param.Val
+		case bytescase.CmpEq(name, []byte("orig-ioi")):
+			pcv.OrigIOI = param.Val
+		case bytescase.CmpEq(name, []byte("term-ioi")):
+			pcv.TermIOI = param.Val
+		}
+		i = next
+		if err != ErrHdrMoreValues {
+			break
+		}
+		param.Reset()
+	}
+	if pcv.ICID.Empty() {
+		return i, ErrHdrValBad
+	}
+	return i, ErrHdrOk
+}
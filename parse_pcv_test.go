@@ -0,0 +1,122 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"testing"
+)
+
+func TestParsePCVVal(t *testing.T) {
+	type testCase struct {
+		v       string
+		icid    string
+		genAt   string
+		origIOI string
+		termIOI string
+		err     ErrorHdr
+	}
+	tests := [...]testCase{
+		{v: "icid-value=\"AyretyU0dm+6O2IrT5tAFrbHLso=023551024\";" +
+			"icid-generated-at=192.0.6.8;orig-ioi=home1.net;term-ioi=home2.net",
+			icid:    "\"AyretyU0dm+6O2IrT5tAFrbHLso=023551024\"",
+			genAt:   "192.0.6.8",
+			origIOI: "home1.net", termIOI: "home2.net"},
+		{v: " ICID-Value = 1234bc9876e ; orig-ioi=type1home1.net",
+			icid: "1234bc9876e", origIOI: "type1home1.net"},
+		{v: "icid-value=1;foo=bar;ggsn=[5555::4b4:3c3:2d2:1e1]",
+			icid: "1"},
+		{v: "orig-ioi=home1.net", err: ErrHdrValBad},
+		{v: "", err: ErrHdrEmpty},
+	}
+	for _, tc := range tests {
+		var pcv PPCVBody
+		b := []byte(tc.v)
+		_, err := ParsePCVVal(b, 0, &pcv)
+		if err != tc.err || pcv.Err != tc.err ||
+			pcv.Parsed() != (err == 0) {
+			t.Errorf("ParsePCVVal(%q) = %d (%q), Err %d, Parsed() %v"+
+				" expected %d (%q)", b, err, err, pcv.Err, pcv.Parsed(),
+				tc.err, tc.err)
+			continue
+		}
+		if err != 0 {
+			continue
+		}
+		if string(pcv.ICID.Get(b)) != tc.icid ||
+			string(pcv.ICIDGenAt.Get(b)) != tc.genAt ||
+			string(pcv.OrigIOI.Get(b)) != tc.origIOI ||
+			string(pcv.TermIOI.Get(b)) != tc.termIOI {
+			t.Errorf("ParsePCVVal(%q): icid %q generated-at %q orig-ioi %q"+
+				" term-ioi %q", b, pcv.ICID.Get(b), pcv.ICIDGenAt.Get(b),
+				pcv.OrigIOI.Get(b), pcv.TermIOI.Get(b))
+		}
+	}
+}
+
+func TestPSIPMsgPCV(t *testing.T) {
+	buf := []byte("INVITE sip:bob@home2.net SIP/2.0\r\n" +
+		"CSeq: 1 INVITE\r\n" +
+		"P-Charging-Vector: icid-value=\"AyretyU0dm+6O2IrT5tAFrbHLso=\";" +
+		"icid-generated-at=192.0.6.8;orig-ioi=home1.net\r\n\r\n")
+	var msg PSIPMsg
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+	pcv := &msg.PV.PCV
+	if !msg.HL.PFlags.Test(HdrPCV) || !pcv.Parsed() || pcv.Err != 0 {
+		t.Fatalf("P-Charging-Vector not parsed: %v %v %d",
+			msg.HL.PFlags.Test(HdrPCV), pcv.Parsed(), pcv.Err)
+	}
+	if string(pcv.ICID.Get(buf)) != "\"AyretyU0dm+6O2IrT5tAFrbHLso=\"" ||
+		string(pcv.OrigIOI.Get(buf)) != "home1.net" {
+		t.Errorf("bad P-Charging-Vector: icid %q orig-ioi %q",
+			pcv.ICID.Get(buf), pcv.OrigIOI.Get(buf))
+	}
+}
+
+// only the first Replaces and P-Charging-Vector headers are parsed, even if
+// they are invalid
+func TestPSIPMsgFirstFullValHdr(t *testing.T) {
+	buf := []byte("INVITE sip:bob@home2.net SIP/2.0\r\n" +
+		"CSeq: 1 INVITE\r\n" +
+		"P-Charging-Vector: orig-ioi=home1.net\r\n" +
+		"Replaces: abc;to-tag=1\r\n" +
+		"P-Charging-Vector: icid-value=1\r\n" +
+		"Replaces: abc\r\n ;to-tag=1;from-tag=2\r\n\r\n")
+	var msg PSIPMsg
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+	if pcv := &msg.PV.PCV; pcv.Parsed() || pcv.Err != ErrHdrValBad ||
+		!pcv.ICID.Empty() {
+		t.Errorf("P-Charging-Vector: Parsed() %v err %d icid %q",
+			pcv.Parsed(), pcv.Err, pcv.ICID.Get(buf))
+	}
+	if r := &msg.PV.Replaces; r.Parsed() || r.Err != ErrHdrValBad ||
+		!r.FromTag.Empty() {
+		t.Errorf("Replaces: Parsed() %v err %d from-tag %q",
+			r.Parsed(), r.Err, r.FromTag.Get(buf))
+	}
+
+	// folded Replaces header
+	buf = []byte("INVITE sip:bob@home2.net SIP/2.0\r\n" +
+		"Replaces: abc@h\r\n ;to-tag=1;from-tag=2\r\n" +
+		"CSeq: 1 INVITE\r\n\r\n")
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+	}
+	if r := &msg.PV.Replaces; !r.Parsed() ||
+		string(r.CallID.Get(buf)) != "abc@h" ||
+		string(r.FromTag.Get(buf)) != "2" {
+		t.Errorf("folded Replaces: Parsed() %v err %d call-id %q"+
+			" from-tag %q", r.Parsed(), r.Err, r.CallID.Get(buf),
+			r.FromTag.Get(buf))
+	}
+}
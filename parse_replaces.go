@@ -0,0 +1,185 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"github.com/intuitivelabs/bytescase"
+)
+
+// PReplacesBody contains a parsed Replaces value (RFC3891), e.g.:
+// 12345@host;to-tag=ab;from-tag=cd;early-only .
+type PReplacesBody struct {
+	CallID    PField   // call-id of the replaced dialog
+	ToTag     PField   // to-tag parameter value
+	FromTag   PField   // from-tag parameter value
+	EarlyOnly bool     // early-only flag present
+	Err       ErrorHdr // parsing error for a Replaces header, 0 on success
+	done      bool     // a value was parsed, successfully or not (see Err)
+}
+
+// Reset re-initializes the parsed values.
+func (r *PReplacesBody) Reset() {
+	*r = PReplacesBody{}
+}
+
+// Empty returns true if nothing has been parsed.
+func (r *PReplacesBody) Empty() bool {
+	return r.CallID.Empty()
+}
+
+// Parsed returns true if a value was successfully parsed.
+func (r *PReplacesBody) Parsed() bool {
+	return r.done && r.Err == 0
+}
+
+// ParseReplacesVal parses a complete Replaces value, contained in
+// buf[offs:] (buf should end at the end of the value) and fills r.
+// The returned error is also saved in r.Err.
+// It returns the offset after the parsed value and ErrHdrOk on success,
+// ErrHdrEmpty for an empty call-id, ErrHdrBadChar for an invalid character
+// after the call-id or ErrHdrValBad if the to-tag or the from-tag parameter
+// is missing or a parameter cannot be parsed.
+func ParseReplacesVal(buf []byte, offs int, r *PReplacesBody) (int, ErrorHdr) {
+	n, err := parseReplacesVal(buf, offs, r)
+	r.done = true
+	r.Err = err
+	return n, err
+}
+
+// parseReplacesVal is the ParseReplacesVal() internal version.
+func parseReplacesVal(buf []byte, offs int, r *PReplacesBody) (int, ErrorHdr) {
+	const flags = POptParamSemiSepF | POptInputEndF
+	var param PTokParam
+
+	i := skipWS(buf, offs)
+	s := i
+	for ; i < len(buf) && buf[i] != ';' && buf[i] != ' ' &&
+		buf[i] != '\t' && buf[i] != '\r' && buf[i] != '\n'; i++ {
+	}
+	if i == s {
+		return i, ErrHdrEmpty
+	}
+	r.CallID.Set(s, i)
+	// skip whitespace, including line folding (the value end is the
+	// input end)
+	i, _, _ = skipLWS(buf, i, POptInputEndF)
+	if i < len(buf) {
+		if buf[i] != ';' {
+			return i, ErrHdrBadChar
+		}
+		i++ // skip over ';'
+		for {
+			next, err := ParseTokenParam(buf, i, &param, flags)
+			if err != 0 && err != ErrHdrMoreValues && err != ErrHdrEOH {
+				return next, ErrHdrValBad
+			}
+			switch name := param.Name.Get(buf); {
+			case bytescase.CmpEq(name, []byte("to-tag")):
+				r.ToTag = param.Val
+			case bytescase.CmpEq(name, []byte("from-tag")):
+				r.FromTag = param.Val
+			case bytescase.CmpEq(name, []byte("early-only")):
+				r.EarlyOnly = true
+			}
+			i = next
+			if err != ErrHdrMoreValues {
+				break
+			}
+			param.Reset()
+		}
+	}
+	if r.ToTag.Empty() || r.FromTag.Empty() {
+		return i, ErrHdrValBad
+	}
+	return i, ErrHdrOk
+}
+
+// uriUnescape appends the %-unescaped s to dst and returns the result.
+// It returns false on an invalid escape sequence.
+func uriUnescape(dst, s []byte) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			dst = append(dst, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return dst, false
+		}
+		h, l := hexDigToI(s[i+1]), hexDigToI(s[i+2])
+		if h < 0 || l < 0 {
+			return dst, false
+		}
+		dst = append(dst, byte(h<<4|l))
+		i += 2
+	}
+	return dst, true
+}
+
+// ReferToReplaces looks for a Replaces header in the headers part of the
+// passed uri (e.g. a Refer-To URI used in an attended transfer:
+// sip:bob@b.com?Replaces=12345%40host%3Bto-tag%3Dab%3Bfrom-tag%3Dcd).
+// If found, the Replaces value is unescaped and appended to dst[:0]
+// (dst can be nil) and then parsed into r (the r fields will point inside
+// the returned slice).
+// It returns the unescaped value and ErrHdrOk on success, ErrHdrEmpty if
+// the uri has no Replaces header (or an empty one), ErrHdrBad if the uri
+// cannot be parsed or the value contains invalid escapes, or one of the
+// ParseReplacesVal() errors.
+func ReferToReplaces(uri []byte, dst []byte, r *PReplacesBody) ([]byte, ErrorHdr) {
+	var puri PsipURI
+	var hdrs [8]URIHdr
+	var l URIHdrsLst
+
+	dst = dst[:0]
+	if err, _ := ParseURI(uri, &puri); err != 0 {
+		return dst, ErrHdrBad
+	}
+	if puri.Headers.Empty() {
+		return dst, ErrHdrEmpty
+	}
+	h := puri.Headers.Get(uri)
+	l.Init(hdrs[:])
+	if _, _, err := ParseAllURIHdrs(h, 0, &l, POptInputEndF); err != 0 &&
+		err != ErrHdrEOH {
+		return dst, ErrHdrBad
+	}
+	for i := 0; i < l.HNo(); i++ {
+		if !bytescase.CmpEq(l.Hdrs[i].Name.Get(h), []byte("replaces")) {
+			continue
+		}
+		var ok bool
+		dst, ok = uriUnescape(dst, l.Hdrs[i].Val.Get(h))
+		if !ok {
+			return dst, ErrHdrBad
+		}
+		r.Reset()
+		_, err := ParseReplacesVal(dst, 0, r)
+		return dst, err
+	}
+	return dst, ErrHdrEmpty
+}
+
+// MatchDialog returns true if the message m belongs to the dialog
+// identified by the Replaces value r (r points inside rbuf): same Call-ID
+// and the same From and To tags, in either direction, since m can be sent
+// by any of the dialog parties.
+// Whether an early-only Replaces can still replace the dialog depends on
+// the dialog state and it is left to the caller.
+// m must be parsed at least up to the end of the headers.
+func (r *PReplacesBody) MatchDialog(rbuf []byte, m *PSIPMsg) bool {
+	if !r.Parsed() || !m.PV.Callid.Parsed() ||
+		!m.PV.From.Parsed() || !m.PV.To.Parsed() {
+		return false
+	}
+	if !r.CallID.Eq(rbuf, m.PV.Callid.CallID, m.Buf) {
+		return false
+	}
+	return (r.FromTag.Eq(rbuf, m.PV.From.Tag, m.Buf) &&
+		r.ToTag.Eq(rbuf, m.PV.To.Tag, m.Buf)) ||
+		(r.FromTag.Eq(rbuf, m.PV.To.Tag, m.Buf) &&
+			r.ToTag.Eq(rbuf, m.PV.From.Tag, m.Buf))
+}
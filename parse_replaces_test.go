@@ -0,0 +1,170 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReplacesVal(t *testing.T) {
+	type testCase struct {
+		v       string
+		callid  string
+		toTag   string
+		fromTag string
+		early   bool
+		err     ErrorHdr
+	}
+	tests := [...]testCase{
+		{v: "12345@192.168.118.3;to-tag=12345;from-tag=5FFE-3994",
+			callid: "12345@192.168.118.3", toTag: "12345",
+			fromTag: "5FFE-3994"},
+		{v: " 98732@sip.example.com ;from-tag=r33th4x0r ; to-tag=ff87ff" +
+			";early-only",
+			callid: "98732@sip.example.com", toTag: "ff87ff",
+			fromTag: "r33th4x0r", early: true},
+		{v: "abc;To-Tag=1;FROM-TAG=2;foo=bar", callid: "abc", toTag: "1",
+			fromTag: "2"},
+		{v: "abc;to-tag=1", err: ErrHdrValBad},
+		{v: "abc", err: ErrHdrValBad},
+		{v: "", err: ErrHdrEmpty},
+		{v: "abc x;to-tag=1;from-tag=2", err: ErrHdrBadChar},
+		// folded value
+		{v: "abc@h\r\n ;to-tag=1;\r\n\tfrom-tag=2", callid: "abc@h",
+			toTag: "1", fromTag: "2"},
+		{v: "abc@h\r\n x;to-tag=1;from-tag=2", err: ErrHdrBadChar},
+	}
+	for _, tc := range tests {
+		var r PReplacesBody
+		b := []byte(tc.v)
+		_, err := ParseReplacesVal(b, 0, &r)
+		if err != tc.err || r.Err != tc.err || r.Parsed() != (err == 0) {
+			t.Errorf("ParseReplacesVal(%q) = %d (%q), Err %d, Parsed() %v"+
+				" expected %d (%q)", b, err, err, r.Err, r.Parsed(),
+				tc.err, tc.err)
+			continue
+		}
+		if err != 0 {
+			continue
+		}
+		if string(r.CallID.Get(b)) != tc.callid ||
+			string(r.ToTag.Get(b)) != tc.toTag ||
+			string(r.FromTag.Get(b)) != tc.fromTag || r.EarlyOnly != tc.early {
+			t.Errorf("ParseReplacesVal(%q): callid %q to-tag %q from-tag %q"+
+				" early-only %v", b, r.CallID.Get(b), r.ToTag.Get(b),
+				r.FromTag.Get(b), r.EarlyOnly)
+		}
+	}
+}
+
+func TestReferToReplaces(t *testing.T) {
+	type testCase struct {
+		uri     string
+		callid  string
+		toTag   string
+		fromTag string
+		err     ErrorHdr
+	}
+	tests := [...]testCase{
+		{uri: "sip:bob@biloxi.example.com?Replaces=12345%40192.168.118.3" +
+			"%3Bto-tag%3D12345%3Bfrom-tag%3D5FFE-3994",
+			callid: "12345@192.168.118.3", toTag: "12345",
+			fromTag: "5FFE-3994"},
+		{uri: "sip:bob@b.com;transport=tcp?Subject=x&replaces=a%40b" +
+			"%3bfrom-tag%3d1%3bto-tag%3d2",
+			callid: "a@b", toTag: "2", fromTag: "1"},
+		{uri: "sip:bob@b.com", err: ErrHdrEmpty},
+		{uri: "sip:bob@b.com?Subject=x", err: ErrHdrEmpty},
+		{uri: "sip:bob@b.com?Replaces=a%4", err: ErrHdrBad},
+		{uri: "sip:bob@b.com?Replaces=a%3Bto-tag%3D1", err: ErrHdrValBad},
+	}
+	for _, tc := range tests {
+		var r PReplacesBody
+		v, err := ReferToReplaces([]byte(tc.uri), nil, &r)
+		if err != tc.err {
+			t.Errorf("ReferToReplaces(%q) = %d (%q), expected %d (%q)",
+				tc.uri, err, err, tc.err, tc.err)
+			continue
+		}
+		if err != 0 {
+			continue
+		}
+		if string(r.CallID.Get(v)) != tc.callid ||
+			string(r.ToTag.Get(v)) != tc.toTag ||
+			string(r.FromTag.Get(v)) != tc.fromTag {
+			t.Errorf("ReferToReplaces(%q): callid %q to-tag %q from-tag %q",
+				tc.uri, r.CallID.Get(v), r.ToTag.Get(v), r.FromTag.Get(v))
+		}
+	}
+}
+
+func TestReplacesMatchDialog(t *testing.T) {
+	const invite = `INVITE sip:bob@biloxi.example.com SIP/2.0\r
+Via: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bKnashds9\r
+From: <sip:carol@chicago.example.com>;tag=4321\r
+To: <sip:bob@biloxi.example.com>\r
+Call-ID: 8734@chicago.example.com\r
+CSeq: 1 INVITE\r
+Replaces: 425928@bobster.example.org;to-tag=7743;from-tag=6472\r
+Replaces: x@y;to-tag=1;from-tag=2\r
+\r
+`
+	// original dialog, request sent by alice
+	const ok200 = `SIP/2.0 200 OK\r
+Via: SIP/2.0/UDP bobster.example.org;branch=z9hG4bK1\r
+From: <sip:bob@biloxi.example.com>;tag=6472\r
+To: <sip:alice@atlanta.example.com>;tag=7743\r
+Call-ID: 425928@bobster.example.org\r
+CSeq: 1 INVITE\r
+\r
+`
+	var inv PSIPMsg
+	b := unescapeCRLF(invite)
+	inv.Init(b, nil, nil)
+	if _, err := ParseSIPMsg(b, 0, &inv, SIPMsgNoMoreDataF); err != 0 {
+		t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", b, err, err)
+	}
+	if !inv.HL.PFlags.Test(HdrReplaces) {
+		t.Fatalf("Replaces header not found")
+	}
+	r := &inv.PV.Replaces
+	if r.Err != 0 || string(r.CallID.Get(b)) != "425928@bobster.example.org" ||
+		string(r.ToTag.Get(b)) != "7743" ||
+		string(r.FromTag.Get(b)) != "6472" {
+		t.Fatalf("bad Replaces: err %d callid %q to-tag %q from-tag %q",
+			r.Err, r.CallID.Get(b), r.ToTag.Get(b), r.FromTag.Get(b))
+	}
+
+	type testCase struct {
+		m     string
+		match bool
+	}
+	tests := [...]testCase{
+		{ok200, true},
+		// in-dialog request in the other direction
+		{strings.NewReplacer("SIP/2.0 200 OK", "BYE sip:b SIP/2.0",
+			"tag=6472", "tag=7743", "tag=7743", "tag=6472").Replace(ok200),
+			true},
+		// different call-id
+		{strings.Replace(ok200, "425928@", "425929@", 1), false},
+		// different tag
+		{strings.Replace(ok200, "tag=7743", "tag=7744", 1), false},
+	}
+	for _, tc := range tests {
+		var m PSIPMsg
+		mb := unescapeCRLF(tc.m)
+		m.Init(mb, nil, nil)
+		if _, err := ParseSIPMsg(mb, 0, &m, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", mb, err, err)
+		}
+		if r.MatchDialog(b, &m) != tc.match {
+			t.Errorf("MatchDialog(%q) = %v, expected %v",
+				mb, !tc.match, tc.match)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"bytes"
+
+	"github.com/intuitivelabs/bytescase"
+)
+
+// SDPDir is the media direction (RFC3264 direction attributes).
+type SDPDir uint8
+
+// SDPDir values. SDPSendRecv is the default (no direction attribute).
+const (
+	SDPSendRecv SDPDir = iota
+	SDPSendOnly
+	SDPRecvOnly
+	SDPInactive
+)
+
+var sdpDirStr = [...]string{
+	SDPSendRecv: "sendrecv",
+	SDPSendOnly: "sendonly",
+	SDPRecvOnly: "recvonly",
+	SDPInactive: "inactive",
+}
+
+// String implements the Stringer interface.
+func (d SDPDir) String() string {
+	if int(d) >= len(sdpDirStr) {
+		return "invalid"
+	}
+	return sdpDirStr[d]
+}
+
+// sdpDirAttr returns the direction corresponding to the attribute a (the
+// part after "a=") and true, or false if a is not a direction attribute.
+func sdpDirAttr(a []byte) (SDPDir, bool) {
+	for d, n := range sdpDirStr {
+		if bytescase.CmpEq(a, []byte(n)) {
+			return SDPDir(d), true
+		}
+	}
+	return SDPSendRecv, false
+}
+
+// PMediaStream contains the parsed information for one SDP media
+// description (m= line).
+type PMediaStream struct {
+	Media    PField // media type (e.g. audio, video)
+	Proto    PField // transport protocol (e.g. RTP/AVP)
+	Port     uint16 // rtp port (0 for a disabled stream)
+	Addr     PField // connection address (media or session level c=)
+	RTCPPort uint16 // rtcp port (a=rtcp or Port+1, 0 if unknown)
+	RTCPAddr PField // rtcp address (a=rtcp or Addr)
+	Dir      SDPDir // media direction (media or session level a=)
+}
+
+// Reset re-initializes the parsed values.
+func (ms *PMediaStream) Reset() {
+	*ms = PMediaStream{}
+}
+
+// PMediaInfo contains the media endpoints parsed from an SDP body.
+type PMediaInfo struct {
+	Addr    PField          // session level connection address
+	Dir     SDPDir          // session level media direction
+	Streams []PMediaStream  // parsed media streams min(N, len(Streams))
+	N       int             // no of media streams found, can be >len(Streams)
+	streams [4]PMediaStream // default streams space
+}
+
+// Reset re-initializes the parsed values.
+func (mi *PMediaInfo) Reset() {
+	for i := 0; i < mi.VNo(); i++ {
+		mi.Streams[i].Reset()
+	}
+	mi.Addr.Reset()
+	mi.Dir = SDPSendRecv
+	mi.N = 0
+}
+
+// Init initializes the media streams space to the passed streams slice
+// (if nil an internal default space will be used) and resets all the
+// other values.
+func (mi *PMediaInfo) Init(streams []PMediaStream) {
+	if streams == nil {
+		streams = mi.streams[:]
+	}
+	mi.Streams = streams
+	mi.Reset()
+}
+
+// VNo returns the number of parsed media streams.
+func (mi *PMediaInfo) VNo() int {
+	if mi.N > len(mi.Streams) {
+		return len(mi.Streams)
+	}
+	return mi.N
+}
+
+// sdpNextTok returns the start and end of the next space separated token
+// in l, starting at offs.
+func sdpNextTok(l []byte, offs int) (int, int) {
+	s := skipWS(l, offs)
+	e := skipToken(l, s)
+	return s, e
+}
+
+// sdpPort converts a port token to a number, ignoring a possible
+// "/number of ports" suffix.
+func sdpPort(p []byte) (uint16, bool) {
+	var v uint32
+	if len(p) == 0 {
+		return 0, false
+	}
+	for i, c := range p {
+		if c == '/' && i > 0 {
+			break
+		}
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		v = v*10 + uint32(c-'0')
+		if v > 0xffff {
+			return 0, false
+		}
+	}
+	return uint16(v), true
+}
+
+// sdpConnAddr parses the value of a c= line (e.g. "IN IP4 1.2.3.4/127")
+// starting at l[offs:] and returns the address (without any "/ttl" or
+// "/number of addresses" suffix).
+func sdpConnAddr(l []byte, offs int) (PField, bool) {
+	var addr PField
+	s, e := sdpNextTok(l, offs) // nettype
+	if s == e {
+		return addr, false
+	}
+	s, e = sdpNextTok(l, e) // addrtype
+	if s == e {
+		return addr, false
+	}
+	s, e = sdpNextTok(l, e) // address
+	if s == e {
+		return addr, false
+	}
+	if i := bytes.IndexByte(l[s:e], '/'); i >= 0 {
+		e = s + i
+	}
+	addr.Set(s, e)
+	return addr, true
+}
+
+// ParseSDP parses the SDP body contained in buf[body.Offs:] and fills mi
+// with the connection addresses (c=) and the media ports (m=) found.
+// Both session level and media level c= lines are supported (a media level
+// one will override the session level address for its media stream).
+// The rtcp port and address are taken from an a=rtcp attribute (RFC3605)
+// if present, else they default to the rtp port + 1 (or 0 for an rtp port
+// of 65535) and the rtp address.
+// The media direction is taken from the media or session level
+// a=sendrecv, a=sendonly, a=recvonly or a=inactive attribute (RFC3264),
+// defaulting to SDPSendRecv.
+// All the returned PFields are offsets in buf.
+// mi should be initialized (see PMediaInfo.Init()) before calling this
+// function.
+// It returns ErrHdrOk on success, ErrHdrEmpty if no media stream was found,
+// ErrHdrTrunc if not all the media streams fit in mi.Streams or ErrHdrValBad
+// if a c= or m= line could not be parsed.
+func ParseSDP(buf []byte, body PField, mi *PMediaInfo) ErrorHdr {
+	var ms *PMediaStream
+	var last PMediaStream // used if no space in mi.Streams
+	var rtcp bool         // a=rtcp seen for the current stream
+
+	// endStream sets the defaults for the current stream
+	endStream := func() {
+		if ms == nil {
+			return
+		}
+		// no implicit rtcp port for a 65535 rtp port (it would wrap to 0)
+		if !rtcp && ms.Port != 0 && ms.Port != 65535 {
+			ms.RTCPPort = ms.Port + 1
+		}
+		if ms.RTCPAddr.Empty() {
+			ms.RTCPAddr = ms.Addr
+		}
+	}
+
+	b := body.Get(buf)
+	for o := 0; o < len(b); {
+		// find the end of the line (CRLF or LF)
+		e := o
+		for ; e < len(b) && b[e] != '\n' && b[e] != '\r'; e++ {
+		}
+		l := b[o:e]
+		lo := int(body.Offs) + o // line offset in buf
+		for o = e; o < len(b) && (b[o] == '\n' || b[o] == '\r'); o++ {
+		}
+		if len(l) < 2 || l[1] != '=' {
+			continue // ignore empty or invalid lines
+		}
+		switch l[0] {
+		case 'c':
+			addr, ok := sdpConnAddr(l, 2)
+			if !ok {
+				return ErrHdrValBad
+			}
+			addr.Offs += OffsT(lo)
+			if ms != nil {
+				ms.Addr = addr
+			} else {
+				mi.Addr = addr
+			}
+		case 'm':
+			endStream()
+			if mi.N < len(mi.Streams) {
+				ms = &mi.Streams[mi.N]
+			} else {
+				ms = &last
+			}
+			ms.Reset()
+			mi.N++
+			rtcp = false
+			ms.Addr = mi.Addr
+			ms.Dir = mi.Dir
+			s, e := sdpNextTok(l, 2)
+			if s == e {
+				return ErrHdrValBad
+			}
+			ms.Media.Set(lo+s, lo+e)
+			s, e = sdpNextTok(l, e)
+			port, ok := sdpPort(l[s:e])
+			if !ok {
+				return ErrHdrValBad
+			}
+			ms.Port = port
+			s, e = sdpNextTok(l, e)
+			ms.Proto.Set(lo+s, lo+e)
+		case 'a':
+			if dir, ok := sdpDirAttr(l[2:]); ok {
+				if ms != nil {
+					ms.Dir = dir
+				} else {
+					mi.Dir = dir
+				}
+				break
+			}
+			// a=rtcp:port [nettype addrtype address]
+			const rtcpAttr = "rtcp:"
+			if ms == nil || len(l) <= 2+len(rtcpAttr) ||
+				!bytescase.CmpEq(l[2:2+len(rtcpAttr)], []byte(rtcpAttr)) {
+				break
+			}
+			s, e := sdpNextTok(l, 2+len(rtcpAttr))
+			port, ok := sdpPort(l[s:e])
+			if !ok {
+				break // ignore bad attribute
+			}
+			rtcp = true
+			ms.RTCPPort = port
+			if addr, ok := sdpConnAddr(l, e); ok {
+				addr.Offs += OffsT(lo)
+				ms.RTCPAddr = addr
+			}
+		}
+	}
+	endStream()
+	if mi.N == 0 {
+		return ErrHdrEmpty
+	}
+	if mi.N > len(mi.Streams) {
+		return ErrHdrTrunc
+	}
+	return ErrHdrOk
+}
+
+// Hold returns true if the parsed SDP (in buf) puts the media on hold:
+// all the enabled (non 0 port) media streams are either sendonly or
+// inactive (RFC3264 8.4) or have a 0.0.0.0 connection address (RFC2543
+// style hold).
+// It returns false if there is no enabled media stream.
+func (mi *PMediaInfo) Hold(buf []byte) bool {
+	active := 0
+	for i := 0; i < mi.VNo(); i++ {
+		ms := &mi.Streams[i]
+		if ms.Port == 0 {
+			continue // disabled stream
+		}
+		active++
+		if ms.Dir != SDPSendOnly && ms.Dir != SDPInactive &&
+			string(ms.Addr.Get(buf)) != "0.0.0.0" {
+			return false
+		}
+	}
+	return active > 0
+}
+
+// BodySDP returns true if the message has a non-empty body and its
+// Content-Type is application/sdp.
+func (m *PSIPMsg) BodySDP() bool {
+	const sdpType = "application/sdp"
+	if m.Body.Empty() || !m.HL.PFlags.Test(HdrCType) {
+		return false
+	}
+	ct := m.HL.GetHdr(HdrCType).Val.Get(m.Buf)
+	if len(ct) < len(sdpType) ||
+		!bytescase.CmpEq(ct[:len(sdpType)], []byte(sdpType)) {
+		return false
+	}
+	// allow only parameters or whitespace after the type
+	if len(ct) > len(sdpType) {
+		c := ct[len(sdpType)]
+		return c == ';' || c == ' ' || c == '\t'
+	}
+	return true
+}
+
+// EarlyMedia returns true if the message is a provisional reply (101-199,
+// e.g. 183 Session Progress) to an INVITE carrying an SDP body (see
+// BodySDP()), which means early media (RFC3960) might be sent before the
+// call is answered.
+// The message must be fully parsed (including the body).
+func (m *PSIPMsg) EarlyMedia() bool {
+	return !m.Request() && m.FL.Status > 100 && m.FL.Status < 200 &&
+		m.PV.CSeq.MethodNo == MInvite && m.BodySDP()
+}
+
+// MediaInfo parses the message body, if the body is SDP (see BodySDP()),
+// and fills mi with the media endpoints (see ParseSDP()).
+// It returns ErrHdrEmpty if the message has no SDP body or no media streams
+// and the same errors as ParseSDP() otherwise.
+func (m *PSIPMsg) MediaInfo(mi *PMediaInfo) ErrorHdr {
+	if !m.BodySDP() {
+		return ErrHdrEmpty
+	}
+	return ParseSDP(m.Buf, m.Body, mi)
+}
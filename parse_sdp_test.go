@@ -0,0 +1,269 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSDP(t *testing.T) {
+	type expStream struct {
+		media    string
+		port     uint16
+		addr     string
+		rtcpPort uint16
+		rtcpAddr string
+	}
+	type testCase struct {
+		sdp     string
+		err     ErrorHdr
+		addr    string // session level address
+		streams []expStream
+	}
+	tests := [...]testCase{
+		{sdp: `v=0\r
+o=alice 2890844526 2890844526 IN IP4 host.atlanta.com\r
+s=-\r
+c=IN IP4 10.0.0.1\r
+t=0 0\r
+m=audio 49170 RTP/AVP 0\r
+a=rtpmap:0 PCMU/8000\r
+m=video 51372 RTP/AVP 31\r
+c=IN IP4 10.0.0.2/127\r
+a=rtcp:53020 IN IP4 10.0.0.3\r
+m=application 0 udp wb\r
+`, err: ErrHdrOk, addr: "10.0.0.1", streams: []expStream{
+			{"audio", 49170, "10.0.0.1", 49171, "10.0.0.1"},
+			{"video", 51372, "10.0.0.2", 53020, "10.0.0.3"},
+			{"application", 0, "10.0.0.1", 0, "10.0.0.1"},
+		}},
+		// media level only, LF line ends, ipv6, rtcp port w/o address
+		{sdp: "v=0\nm=audio 4000/2 RTP/AVP 8\nc=IN IP6 2001:db8::1\n" +
+			"a=rtcp:4010\n", err: ErrHdrOk, streams: []expStream{
+			{"audio", 4000, "2001:db8::1", 4010, "2001:db8::1"},
+		}},
+		// highest rtp port: no implicit rtcp port
+		{sdp: "v=0\r\nc=IN IP4 10.0.0.1\r\nm=audio 65535 RTP/AVP 0\r\n" +
+			"m=audio 65534 RTP/AVP 0\r\n", err: ErrHdrOk,
+			addr: "10.0.0.1", streams: []expStream{
+				{"audio", 65535, "10.0.0.1", 0, "10.0.0.1"},
+				{"audio", 65534, "10.0.0.1", 65535, "10.0.0.1"},
+			}},
+		// no media
+		{sdp: "v=0\r\nc=IN IP4 10.0.0.1\r\n", err: ErrHdrEmpty,
+			addr: "10.0.0.1"},
+		// bad port
+		{sdp: "v=0\r\nm=audio 70000 RTP/AVP 0\r\n", err: ErrHdrValBad},
+		{sdp: "v=0\r\nc=IN IP4\r\nm=audio 7000 RTP/AVP 0\r\n",
+			err: ErrHdrValBad},
+	}
+	for _, tc := range tests {
+		buf := []byte("XX" + string(unescapeCRLF(tc.sdp))) // non 0 offset
+		var body PField
+		body.Set(2, len(buf))
+		var mi PMediaInfo
+		mi.Init(nil)
+		err := ParseSDP(buf, body, &mi)
+		if err != tc.err {
+			t.Errorf("ParseSDP(%q) = %d (%q) expected %d (%q)",
+				buf, err, err, tc.err, tc.err)
+			continue
+		}
+		if string(mi.Addr.Get(buf)) != tc.addr {
+			t.Errorf("ParseSDP(%q): session addr %q expected %q",
+				buf, mi.Addr.Get(buf), tc.addr)
+		}
+		if err != ErrHdrOk {
+			continue
+		}
+		if mi.VNo() != len(tc.streams) {
+			t.Errorf("ParseSDP(%q): %d streams expected %d",
+				buf, mi.VNo(), len(tc.streams))
+			continue
+		}
+		for i, e := range tc.streams {
+			s := &mi.Streams[i]
+			if string(s.Media.Get(buf)) != e.media || s.Port != e.port ||
+				string(s.Addr.Get(buf)) != e.addr ||
+				s.RTCPPort != e.rtcpPort ||
+				string(s.RTCPAddr.Get(buf)) != e.rtcpAddr {
+				t.Errorf("ParseSDP(%q): stream %d: %q %d %q rtcp %d %q,"+
+					" expected %v", buf, i, s.Media.Get(buf), s.Port,
+					s.Addr.Get(buf), s.RTCPPort, s.RTCPAddr.Get(buf), e)
+			}
+		}
+	}
+	// more streams than space
+	buf := []byte("m=audio 1 RTP/AVP 0\r\nm=audio 2 RTP/AVP 0\r\n")
+	var body PField
+	body.Set(0, len(buf))
+	var mi PMediaInfo
+	mi.Init(make([]PMediaStream, 1))
+	if err := ParseSDP(buf, body, &mi); err != ErrHdrTrunc ||
+		mi.N != 2 || mi.VNo() != 1 {
+		t.Errorf("ParseSDP(%q) = %d (%q), N %d expected %q, N 2",
+			buf, err, err, mi.N, ErrHdrTrunc)
+	}
+}
+
+func TestPSIPMsgMediaInfo(t *testing.T) {
+	type testCase struct {
+		m    string
+		sdp  bool
+		port uint16
+	}
+	tests := [...]testCase{
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Content-Type: application/SDP\r
+Content-Length: 42\r
+\r
+c=IN IP4 1.2.3.4\r
+m=audio 8000 RTP/AVP 0\r
+`, sdp: true, port: 8000},
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+c: application/sdp;charset=utf-8\r
+l: 24\r
+\r
+m=audio 8000 RTP/AVP 0\r
+`, sdp: true, port: 8000},
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Content-Type: application/sdpx\r
+Content-Length: 24\r
+\r
+m=audio 8000 RTP/AVP 0\r
+`, sdp: false},
+		{m: `INVITE sip:bob@biloxi.com SIP/2.0\r
+Content-Type: application/sdp\r
+Content-Length: 0\r
+\r
+`, sdp: false},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := unescapeCRLF(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if msg.BodySDP() != tc.sdp {
+			t.Errorf("BodySDP(%q) = %v expected %v",
+				buf, msg.BodySDP(), tc.sdp)
+		}
+		var mi PMediaInfo
+		mi.Init(nil)
+		err := msg.MediaInfo(&mi)
+		if !tc.sdp {
+			if err != ErrHdrEmpty {
+				t.Errorf("MediaInfo(%q) = %d (%q) expected %q",
+					buf, err, err, ErrHdrEmpty)
+			}
+			continue
+		}
+		if err != ErrHdrOk || mi.VNo() != 1 || mi.Streams[0].Port != tc.port {
+			t.Errorf("MediaInfo(%q) = %d (%q) %d streams expected port %d",
+				buf, err, err, mi.VNo(), tc.port)
+		}
+	}
+}
+
+func TestParseSDPDir(t *testing.T) {
+	type testCase struct {
+		sdp  string
+		dir  SDPDir   // session level
+		dirs []SDPDir // per stream
+		hold bool
+	}
+	tests := [...]testCase{
+		// re-INVITE putting the call on hold
+		{"c=IN IP4 10.0.0.1\r\nm=audio 49170 RTP/AVP 0\r\na=sendonly\r\n",
+			SDPSendRecv, []SDPDir{SDPSendOnly}, true},
+		// resume
+		{"c=IN IP4 10.0.0.1\r\nm=audio 49170 RTP/AVP 0\r\na=sendrecv\r\n",
+			SDPSendRecv, []SDPDir{SDPSendRecv}, false},
+		{"c=IN IP4 10.0.0.1\r\nm=audio 49170 RTP/AVP 0\r\n",
+			SDPSendRecv, []SDPDir{SDPSendRecv}, false},
+		// session level, overridden by media level
+		{"c=IN IP4 10.0.0.1\r\na=inactive\r\nm=audio 49170 RTP/AVP 0\r\n" +
+			"m=video 49172 RTP/AVP 31\r\na=RECVONLY\r\n",
+			SDPInactive, []SDPDir{SDPInactive, SDPRecvOnly}, false},
+		{"c=IN IP4 10.0.0.1\r\na=inactive\r\nm=audio 49170 RTP/AVP 0\r\n" +
+			"m=video 0 RTP/AVP 31\r\n",
+			SDPInactive, []SDPDir{SDPInactive, SDPInactive}, true},
+		// rfc2543 hold
+		{"c=IN IP4 0.0.0.0\r\nm=audio 49170 RTP/AVP 0\r\n",
+			SDPSendRecv, []SDPDir{SDPSendRecv}, true},
+		// no enabled streams
+		{"c=IN IP4 10.0.0.1\r\nm=audio 0 RTP/AVP 0\r\na=sendonly\r\n",
+			SDPSendRecv, []SDPDir{SDPSendOnly}, false},
+	}
+	for _, tc := range tests {
+		buf := []byte(tc.sdp)
+		var body PField
+		body.Set(0, len(buf))
+		var mi PMediaInfo
+		mi.Init(nil)
+		if err := ParseSDP(buf, body, &mi); err != ErrHdrOk {
+			t.Errorf("ParseSDP(%q) = %d (%q)", buf, err, err)
+			continue
+		}
+		if mi.Dir != tc.dir {
+			t.Errorf("ParseSDP(%q): session dir %s expected %s",
+				buf, mi.Dir, tc.dir)
+		}
+		if mi.VNo() != len(tc.dirs) {
+			t.Errorf("ParseSDP(%q): %d streams expected %d",
+				buf, mi.VNo(), len(tc.dirs))
+			continue
+		}
+		for i, d := range tc.dirs {
+			if mi.Streams[i].Dir != d {
+				t.Errorf("ParseSDP(%q): stream %d dir %s expected %s",
+					buf, i, mi.Streams[i].Dir, d)
+			}
+		}
+		if h := mi.Hold(buf); h != tc.hold {
+			t.Errorf("Hold(%q) = %v expected %v", buf, h, tc.hold)
+		}
+	}
+}
+
+func TestPSIPMsgEarlyMedia(t *testing.T) {
+	const sdp = "v=0\r\nc=IN IP4 1.2.3.4\r\nm=audio 8000 RTP/AVP 0\r\n"
+	const r183 = "SIP/2.0 183 Session Progress\r\n" +
+		"CSeq: 1 INVITE\r\nContent-Type: application/sdp\r\n" +
+		"Content-Length: 47\r\n\r\n" + sdp
+	type testCase struct {
+		m     string
+		early bool
+	}
+	tests := [...]testCase{
+		{r183, true},
+		{strings.Replace(r183, "183 Session Progress", "180 Ringing", 1),
+			true},
+		// no body
+		{"SIP/2.0 183 Session Progress\r\nCSeq: 1 INVITE\r\n" +
+			"Content-Length: 0\r\n\r\n", false},
+		{strings.Replace(r183, "183 Session Progress", "100 Trying", 1),
+			false},
+		{strings.Replace(r183, "183 Session Progress", "200 OK", 1), false},
+		{strings.Replace(r183, "1 INVITE", "1 UPDATE", 1), false},
+		{strings.Replace(r183, "SIP/2.0 183 Session Progress",
+			"INVITE sip:bob@biloxi.com SIP/2.0", 1), false},
+	}
+	for _, tc := range tests {
+		var msg PSIPMsg
+		buf := []byte(tc.m)
+		msg.Init(buf, nil, nil)
+		if _, err := ParseSIPMsg(buf, 0, &msg, SIPMsgNoMoreDataF); err != 0 {
+			t.Fatalf("ParseSIPMsg(%q) failed: %d (%q)", buf, err, err)
+		}
+		if e := msg.EarlyMedia(); e != tc.early {
+			t.Errorf("EarlyMedia(%q) = %v expected %v", buf, e, tc.early)
+		}
+	}
+}
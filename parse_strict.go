@@ -0,0 +1,110 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"fmt"
+)
+
+// ParseError is a detailed parsing error, returned by ParseSIPMsgStrict().
+// It contains the ErrorHdr error code, the header in which the error was
+// found and the offending offset and character.
+type ParseError struct {
+	Err    ErrorHdr // error code
+	Hdr    HdrT     // header type, HdrNone for the first line or the body
+	Offs   int      // offset in the message buffer
+	Char   byte     // character at Offs, 0 if Offs is past the end
+	URIErr ErrorURI // URI error, set only if an URI is invalid
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	where := "first line"
+	if e.Hdr != HdrNone {
+		where = e.Hdr.String() + " header"
+	}
+	if e.URIErr != NoURIErr {
+		return fmt.Sprintf("%s in %s at offset %d (%q): %s",
+			e.Err.Error(), where, e.Offs, e.Char, e.URIErr.Error())
+	}
+	return fmt.Sprintf("%s in %s at offset %d (%q)",
+		e.Err.Error(), where, e.Offs, e.Char)
+}
+
+// Unwrap returns the ErrorHdr error code (for errors.Is()).
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError fills a ParseError for an error at offset offs in buf.
+func newParseError(err ErrorHdr, hdr HdrT, buf []byte, offs int,
+	uerr ErrorURI) *ParseError {
+	e := &ParseError{Err: err, Hdr: hdr, Offs: offs, URIErr: uerr}
+	if offs >= 0 && offs < len(buf) {
+		e.Char = buf[offs]
+	}
+	return e
+}
+
+// ParseSIPMsgStrict is a slower ParseSIPMsg() variant that returns a
+// detailed *ParseError on failure and also checks the request URI and the
+// From and To URIs (using ParseURI()).
+// The parameters are the same as for ParseSIPMsg().
+// ErrHdrMoreBytes is returned as such (not wrapped), so that parsing can be
+// resumed when more data is available. On success it returns nil.
+// For performance critical code, ParseSIPMsg() should be used instead.
+func ParseSIPMsgStrict(buf []byte, offs int, msg *PSIPMsg,
+	flags uint8) (int, error) {
+
+	o, err := ParseSIPMsg(buf, offs, msg, flags)
+	switch err {
+	case ErrHdrOk:
+	case ErrHdrMoreBytes:
+		return o, err
+	default:
+		hdr := HdrNone
+		switch {
+		case !msg.FL.Parsed():
+		case err == ErrHdrBadCLen || err == ErrHdrNoCLen:
+			if msg.PV.CLen.Parsed() {
+				hdr = HdrCLen
+			}
+		case msg.HL.N < len(msg.HL.Hdrs):
+			hdr = msg.HL.Hdrs[msg.HL.N].Type
+		default:
+			hdr = msg.HL.hdr.Type
+		}
+		return o, newParseError(err, hdr, buf, o, NoURIErr)
+	}
+
+	var puri PsipURI
+	if msg.FL.Request() {
+		uri := msg.FL.URI
+		if uerr, n := ParseURI(uri.Get(buf), &puri); uerr != NoURIErr {
+			return o, newParseError(ErrHdrValBad, HdrNone, buf,
+				int(uri.Offs)+n, uerr)
+		}
+	}
+	addr := [...]struct {
+		h   HdrT
+		uri PField
+	}{
+		{HdrFrom, msg.PV.From.URI},
+		{HdrTo, msg.PV.To.URI},
+	}
+	for _, a := range addr {
+		if a.uri.Empty() {
+			continue
+		}
+		puri.Reset()
+		if uerr, n := ParseURI(a.uri.Get(buf), &puri); uerr != NoURIErr {
+			return o, newParseError(ErrHdrValBad, a.h, buf,
+				int(a.uri.Offs)+n, uerr)
+		}
+	}
+	return o, nil
+}
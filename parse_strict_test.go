@@ -0,0 +1,97 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSIPMsgStrict(t *testing.T) {
+	const invite = "INVITE sip:bob@biloxi.com SIP/2.0\r\n" +
+		"From: <sip:alice@atlanta.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@biloxi.com>\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.com\r\n" +
+		"CSeq: 314159 INVITE\r\n\r\n"
+	type testCase struct {
+		old, new string // replace old with new in invite
+		err      ErrorHdr
+		hdr      HdrT
+		at       string // the error offset is the start of at in the msg
+		uerr     ErrorURI
+	}
+	tests := [...]testCase{
+		{"", "", 0, HdrNone, "", NoURIErr},
+		// bad From
+		{"<sip:alice@atlanta.com>;tag", "<sip:alice@atlanta.com;tag",
+			ErrHdrBadChar, HdrFrom, "\r\nTo:", NoURIErr},
+		// bad From URI
+		{"<sip:alice@atlanta.com>", "<sip:alice@atlanta.com:5x60>",
+			ErrHdrValBad, HdrFrom, "x60>", ErrURIPort},
+		// bad To URI
+		{"<sip:bob@biloxi.com>", "<sip:bob@biloxi.com:5x60>",
+			ErrHdrValBad, HdrTo, "x60>", ErrURIPort},
+		// bad request URI
+		{"sip:bob@biloxi.com SIP", "foo:bob@biloxi.com SIP",
+			ErrHdrValBad, HdrNone, "bob@biloxi.com SIP", ErrURIScheme},
+		// bad first line
+		{"SIP/2.0\r\nFrom", "SIP/3.0\r\nFrom",
+			ErrHdrBadVer, HdrNone, "", NoURIErr},
+	}
+	for _, tc := range tests {
+		m := invite
+		if tc.old != "" {
+			m = strings.Replace(invite, tc.old, tc.new, 1)
+		}
+		var msg PSIPMsg
+		buf := []byte(m)
+		msg.Init(buf, nil, nil)
+		_, err := ParseSIPMsgStrict(buf, 0, &msg, SIPMsgNoMoreDataF)
+		if tc.err == 0 {
+			if err != nil {
+				t.Errorf("ParseSIPMsgStrict(%q) failed: %s", buf, err)
+			}
+			continue
+		}
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("ParseSIPMsgStrict(%q) = %v, expected a ParseError",
+				buf, err)
+			continue
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("ParseSIPMsgStrict(%q) = %s, expected %q",
+				buf, err, tc.err)
+		}
+		if perr.Err != tc.err || perr.Hdr != tc.hdr ||
+			perr.URIErr != tc.uerr {
+			t.Errorf("ParseSIPMsgStrict(%q) = %#v, expected err %d hdr %s"+
+				" uri err %d", buf, *perr, tc.err, tc.hdr, tc.uerr)
+		}
+		if tc.at != "" {
+			offs := strings.Index(m, tc.at)
+			if perr.Offs != offs || perr.Char != m[offs] {
+				t.Errorf("ParseSIPMsgStrict(%q): error at %d (%q),"+
+					" expected %d (%q)", buf, perr.Offs, perr.Char,
+					offs, m[offs])
+			}
+		}
+		if perr.Error() == "" {
+			t.Errorf("ParseSIPMsgStrict(%q): empty error string", buf)
+		}
+	}
+
+	// partial message: ErrHdrMoreBytes is not wrapped
+	var msg PSIPMsg
+	buf := []byte(invite[:len(invite)-10])
+	msg.Init(buf, nil, nil)
+	if _, err := ParseSIPMsgStrict(buf, 0, &msg, 0); err != ErrHdrMoreBytes {
+		t.Errorf("ParseSIPMsgStrict(%q) = %v, expected %q",
+			buf, err, ErrHdrMoreBytes)
+	}
+}
@@ -7,6 +7,12 @@
 //Package sipsp implements SIP message parsing.
 package sipsp
 
+import (
+	"bytes"
+
+	"github.com/intuitivelabs/bytescase"
+)
+
 //OffsT is the type used for offset and length used internally in PField.
 type OffsT uint16 // uint16 since max buf & msg size <= 65k
 
@@ -55,8 +61,76 @@ func (p PField) Get(buf []byte) []byte {
 	return GetPField(buf, p)
 }
 
+// Eq returns true if the content of p in buf is equal to the content
+// of f2 in buf2 (case-sensitive comparison).
+func (p PField) Eq(buf []byte, f2 PField, buf2 []byte) bool {
+	return p.Len == f2.Len && bytes.Equal(p.Get(buf), f2.Get(buf2))
+}
+
+// EqFold is similar to Eq, but it uses a case-insensitive comparison
+// (ASCII only).
+func (p PField) EqFold(buf []byte, f2 PField, buf2 []byte) bool {
+	return p.Len == f2.Len && bytescase.CmpEq(p.Get(buf), f2.Get(buf2))
+}
+
+// ContainsFold returns true if the content of p in buf contains sub
+// (case-insensitive ASCII comparison, see ContainsFold()).
+func (p PField) ContainsFold(buf []byte, sub []byte) bool {
+	return ContainsFold(p.Get(buf), sub)
+}
+
+// IndexFold returns the index of the first instance of sub in s or -1 if
+// sub is not present in s. The comparison is case-insensitive (ASCII only).
+func IndexFold(s, sub []byte) int {
+	if len(sub) == 0 {
+		return 0
+	}
+	first := bytescase.ByteToLower(sub[0])
+	for i := 0; i <= len(s)-len(sub); i++ {
+		if bytescase.ByteToLower(s[i]) == first &&
+			bytescase.CmpEq(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ContainsFold returns true if sub is found inside s, using a
+// case-insensitive comparison (ASCII only).
+// It is the case-insensitive version of bytes.Contains() (useful for
+// example for matching URI hosts).
+func ContainsFold(s, sub []byte) bool {
+	return IndexFold(s, sub) >= 0
+}
+
 // GetPField returns a byte slice for the corresponding field f, pointing
 // inside buf.
 func GetPField(buf []byte, f PField) []byte {
 	return buf[f.Offs : f.Offs+f.Len]
 }
+
+// AppendBytes copies src into dst, starting at offset offs.
+// It returns a PField pointing to the copied data inside dst, the offset
+// immediately after the copied data (the next free position) and true on
+// success.
+// If there is not enough space in dst or the resulting offset would not fit
+// in a PField, nothing is copied and it returns an empty PField, offs and
+// false.
+func AppendBytes(dst []byte, offs int, src []byte) (PField, int, bool) {
+	var f PField
+	end := offs + len(src)
+	if offs < 0 || end > len(dst) || end > int(^OffsT(0)) {
+		return f, offs, false
+	}
+	copy(dst[offs:], src)
+	f.Set(offs, end)
+	return f, end, true
+}
+
+// AppendPField copies the content of field f from buf into dst,
+// starting at offset offs.
+// It returns the new PField pointing inside dst, the next free offset in
+// dst and true on success. See AppendBytes() for more details.
+func AppendPField(dst []byte, offs int, buf []byte, f PField) (PField, int, bool) {
+	return AppendBytes(dst, offs, f.Get(buf))
+}
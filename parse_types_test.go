@@ -0,0 +1,148 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAppendBytes(t *testing.T) {
+	type testCase struct {
+		dst  int    // dst buffer size
+		offs int    // append offset
+		src  string // appended value
+		ok   bool   // expected result
+	}
+	tests := [...]testCase{
+		{10, 0, "foo", true},
+		{10, 7, "foo", true},  // exact fit
+		{10, 8, "foo", false}, // one byte over
+		{3, 0, "foo", true},   // exact fit, whole buffer
+		{2, 0, "foo", false},  // one byte over, whole buffer
+		{10, 10, "", true},    // empty value at the end
+		{10, 11, "", false},   // offset past the end
+	}
+	for _, tc := range tests {
+		dst := make([]byte, tc.dst)
+		f, n, ok := AppendBytes(dst, tc.offs, []byte(tc.src))
+		if ok != tc.ok {
+			t.Errorf("AppendBytes([%d], %d, %q) = %v, expected %v",
+				tc.dst, tc.offs, tc.src, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			if n != tc.offs || !f.Empty() {
+				t.Errorf("AppendBytes([%d], %d, %q) failed but returned"+
+					" offset %d and field %v", tc.dst, tc.offs, tc.src, n, f)
+			}
+			if !bytes.Equal(dst, make([]byte, tc.dst)) {
+				t.Errorf("AppendBytes([%d], %d, %q) failed but modified dst",
+					tc.dst, tc.offs, tc.src)
+			}
+			continue
+		}
+		if n != tc.offs+len(tc.src) {
+			t.Errorf("AppendBytes([%d], %d, %q) returned offset %d,"+
+				" expected %d", tc.dst, tc.offs, tc.src, n,
+				tc.offs+len(tc.src))
+		}
+		if int(f.Offs) != tc.offs || string(f.Get(dst)) != tc.src {
+			t.Errorf("AppendBytes([%d], %d, %q) returned field %v (%q)",
+				tc.dst, tc.offs, tc.src, f, f.Get(dst))
+		}
+	}
+}
+
+func TestAppendPField(t *testing.T) {
+	buf := []byte("Call-ID: abcd")
+	var callid, from PField
+	callid.Set(9, 13)
+	from.Set(0, 4)
+
+	dst := make([]byte, 8)
+	f1, n, ok := AppendPField(dst, 0, buf, callid)
+	if !ok {
+		t.Fatalf("AppendPField(%q) failed", callid.Get(buf))
+	}
+	f2, n, ok := AppendPField(dst, n, buf, from)
+	if !ok {
+		t.Fatalf("AppendPField(%q) failed", from.Get(buf))
+	}
+	if n != len(dst) || string(dst) != "abcdCall" ||
+		string(f1.Get(dst)) != "abcd" || string(f2.Get(dst)) != "Call" {
+		t.Errorf("AppendPField: unexpected result %q (%q, %q, %d)",
+			dst, f1.Get(dst), f2.Get(dst), n)
+	}
+	if _, _, ok := AppendPField(dst, n, buf, from); ok {
+		t.Errorf("AppendPField on full buffer succeeded")
+	}
+}
+
+func TestPFieldEq(t *testing.T) {
+	type testCase struct {
+		a, b   string
+		eq     bool
+		eqFold bool
+	}
+	tests := [...]testCase{
+		{"foo", "foo", true, true},
+		{"Foo.COM", "foo.com", false, true},
+		{"foo", "fo", false, false},
+		{"", "", true, true},
+		{"foo", "bar", false, false},
+	}
+	for _, tc := range tests {
+		// use different offsets in the 2 buffers
+		buf1 := []byte("xx" + tc.a)
+		buf2 := []byte("yyyy" + tc.b + "z")
+		var f1, f2 PField
+		f1.Set(2, len(buf1))
+		f2.Set(4, len(buf2)-1)
+		if r := f1.Eq(buf1, f2, buf2); r != tc.eq {
+			t.Errorf("Eq(%q, %q) = %v expected %v", tc.a, tc.b, r, tc.eq)
+		}
+		if r := f1.EqFold(buf1, f2, buf2); r != tc.eqFold {
+			t.Errorf("EqFold(%q, %q) = %v expected %v",
+				tc.a, tc.b, r, tc.eqFold)
+		}
+	}
+}
+
+func TestContainsFold(t *testing.T) {
+	type testCase struct {
+		s, sub string
+		idx    int
+	}
+	tests := [...]testCase{
+		{"sip:a@example.com", "EXAMPLE.com", 6},
+		{"sip:a@example.com", "example.com", 6},
+		{"SIP:A@EXAMPLE.COM", "a@example", 4},
+		{"sip:a@example.com", "example.org", -1},
+		{"sip:a@example.com", "", 0},
+		{"", "a", -1},
+		{"exa", "example", -1},
+		{"eExample", "EXAMPLE", 1},
+	}
+	for _, tc := range tests {
+		if i := IndexFold([]byte(tc.s), []byte(tc.sub)); i != tc.idx {
+			t.Errorf("IndexFold(%q, %q) = %d expected %d",
+				tc.s, tc.sub, i, tc.idx)
+		}
+		if r := ContainsFold([]byte(tc.s), []byte(tc.sub)); r != (tc.idx >= 0) {
+			t.Errorf("ContainsFold(%q, %q) = %v expected %v",
+				tc.s, tc.sub, r, tc.idx >= 0)
+		}
+		buf := []byte("xx" + tc.s + "y")
+		var f PField
+		f.Set(2, len(buf)-1)
+		if r := f.ContainsFold(buf, []byte(tc.sub)); r != (tc.idx >= 0) {
+			t.Errorf("PField.ContainsFold(%q, %q) = %v expected %v",
+				tc.s, tc.sub, r, tc.idx >= 0)
+		}
+	}
+}
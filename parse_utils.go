@@ -179,3 +179,25 @@ func skipLine(buf []byte, offs int) (int, int, ErrorHdr) {
 	}
 	return skipCRLF(buf, offs)
 }
+
+// TrimLWS returns a new PField with all the leading and trailing linear
+// whitespace (space, tab, CR or LF, so including folded CRLF SP) removed
+// from f (f points inside buf).
+// If f contains only whitespace, an empty PField is returned.
+// It can be used to normalize parsed values that might keep trailing
+// whitespace (e.g. see PFromBody.Trim()).
+func TrimLWS(buf []byte, f PField) PField {
+	s := int(f.Offs)
+	e := s + int(f.Len)
+	for ; s < e && (buf[s] == ' ' || buf[s] == '\t' ||
+		buf[s] == '\r' || buf[s] == '\n'); s++ {
+	}
+	for ; e > s && (buf[e-1] == ' ' || buf[e-1] == '\t' ||
+		buf[e-1] == '\r' || buf[e-1] == '\n'); e-- {
+	}
+	var r PField
+	if s < e {
+		r.Set(s, e)
+	}
+	return r
+}
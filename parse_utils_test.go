@@ -95,3 +95,29 @@ func TestSkipLWS(t *testing.T) {
 		}
 	}
 }
+
+func TestTrimLWS(t *testing.T) {
+	type testCase struct {
+		b    string
+		offs int // field start
+		res  string
+	}
+	tests := [...]testCase{
+		{"p=\r\n v", 2, "v"},
+		{"p=\r\n\tv \r\n w\t\r\n ", 2, "v \r\n w"},
+		{"  abc  ", 0, "abc"},
+		{"abc", 0, "abc"},
+		{"p= \r\n \t", 2, ""},
+		{"", 0, ""},
+	}
+	for _, tc := range tests {
+		b := []byte(tc.b)
+		var f PField
+		f.Set(tc.offs, len(b))
+		r := TrimLWS(b, f)
+		if string(r.Get(b)) != tc.res {
+			t.Errorf("TrimLWS(%q, %d) = %q expected %q",
+				b, tc.offs, r.Get(b), tc.res)
+		}
+	}
+}
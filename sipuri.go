@@ -6,13 +6,6 @@
 
 package sipsp
 
-import (
-	//	"errors"
-	"bytes"
-
-	"github.com/intuitivelabs/bytescase"
-)
-
 // SIPStr is the "string" type used by all the sip parsing functions.
 type SIPStr []byte
 
@@ -30,6 +23,7 @@ const (
 	ErrURITooShort
 	ErrURIBad
 	ErrURIBug
+	ErrURITooLong
 )
 
 var errURIStr = [...]string{
@@ -42,12 +36,38 @@ var errURIStr = [...]string{
 	ErrURITooShort: "uri too short",
 	ErrURIBad:      "bad URI",
 	ErrURIBug:      "internal BUG while parsing the URI",
+	ErrURITooLong:  "uri too long",
 }
 
 func (e ErrorURI) Error() string {
 	return errURIStr[e]
 }
 
+// ParseURI() limits. Uris exceeding any of them are rejected with
+// ErrURITooLong. They can be lowered to limit the work done on
+// pathological inputs.
+// They are read on each ParseURI() call without any locking, so they
+// should be changed only at init time, before parsing any uri.
+var (
+	// URIMaxLen is the maximum uri length. Uris longer then the maximum
+	// PField offset (the default) are always rejected.
+	URIMaxLen = int(^OffsT(0))
+	// URIMaxCompLen is the maximum length of an uri component, where the
+	// components are separated by any of ':', ';', '?', '&', '@' or '='
+	// (e.g. user, host, parameter name or value, header name or value).
+	URIMaxCompLen = 4096
+	// URIMaxParams is the maximum number of ';' in an uri (the maximum
+	// number of parameters).
+	// The counting restarts after the user part ('@'), but a user part
+	// can contain ';' (e.g. sip:user;x@foo.bar) and they are counted too
+	// (so a user part cannot contain more then URIMaxParams ';').
+	URIMaxParams = 64
+	// URIMaxHeaders is the maximum number of '&' separated headers.
+	// Like for URIMaxParams, '&' inside the user part are also counted,
+	// until the '@' is found.
+	URIMaxHeaders = 64
+)
+
 // URIScheme is the type for possible uri schemes (sips, sip, tel...).
 type URIScheme int8
 
@@ -203,8 +223,31 @@ const (
 	URICmpSkipPass
 	URICmpSkipParams
 	URICmpSkipHeaders
+	URICmpSIPSAsSIP // sip and sips schemes are equal (other schemes not)
+	URICmpDefPort   // missing port == default port (5060 sip, 5061 sips)
 )
 
+// uriCmpPort returns the port of u to be used in comparisons.
+func uriCmpPort(u *PsipURI, flags URICmpFlags) uint16 {
+	if u.PortNo == 0 && (flags&URICmpDefPort) != 0 {
+		switch u.URIType {
+		case SIPuri:
+			return 5060
+		case SIPSuri:
+			return 5061
+		}
+	}
+	return u.PortNo
+}
+
+// uriCmpScheme returns the scheme of u to be used in comparisons.
+func uriCmpScheme(u *PsipURI, flags URICmpFlags) URIScheme {
+	if u.URIType == SIPSuri && (flags&URICmpSIPSAsSIP) != 0 {
+		return SIPuri
+	}
+	return u.URIType
+}
+
 // URICmpShort compares 2 "shortened" uris (up to port, not including
 // parameters or headers).
 // Parameters: u1 is a parsed sip uri, buf1 contains the data to which
@@ -214,16 +257,21 @@ const (
 // common parameters must match, user, ttl, method and maddr must either appear
 // in both URIs or in none and any present header must appear in both URIs to
 // match).
+// With URICmpSIPSAsSIP sip: and sips: uris are considered equal and with
+// URICmpDefPort a missing port is considered equal to the scheme default
+// port (e.g. sip:host == sip:host:5060). Note that when both flags are used
+// the default port is still the one of each uri scheme (sips:host will
+// not match sip:host:5060).
 //
 func URICmpShort(u1 *PsipURI, buf1 []byte, u2 *PsipURI, buf2 []byte,
 	flags URICmpFlags) bool {
-	return ((flags&URICmpSkipScheme) != 0 || (u1.URIType == u2.URIType)) &&
-		((flags&URICmpSkipPort) != 0 || (u1.PortNo == u2.PortNo)) &&
-		((flags&URICmpSkipUser) != 0 ||
-			bytes.Equal(u1.User.Get(buf1), u2.User.Get(buf2))) &&
-		((flags&URICmpSkipPass) != 0 ||
-			bytes.Equal(u1.Pass.Get(buf1), u2.Pass.Get(buf2))) &&
-		bytescase.CmpEq(u1.Host.Get(buf1), u2.Host.Get(buf2))
+	return ((flags&URICmpSkipScheme) != 0 ||
+		(uriCmpScheme(u1, flags) == uriCmpScheme(u2, flags))) &&
+		((flags&URICmpSkipPort) != 0 ||
+			(uriCmpPort(u1, flags) == uriCmpPort(u2, flags))) &&
+		((flags&URICmpSkipUser) != 0 || u1.User.Eq(buf1, u2.User, buf2)) &&
+		((flags&URICmpSkipPass) != 0 || u1.Pass.Eq(buf1, u2.Pass, buf2)) &&
+		u1.Host.EqFold(buf1, u2.Host, buf2)
 }
 
 // URICmp compares 2 URIs, taking into account the passed flags.
@@ -282,6 +330,12 @@ func URIRawCmp(rawURI1 []byte, rawURI2 []byte,
 // ParseURI parses a sip uri into a PSIPUri structure.
 // Note: PsipURI members will point into the original SipStr
 // Returns err = 0 on success, or error and the parsed-so-far offset.
+// Uris exceeding URIMaxLen, URIMaxCompLen, URIMaxParams or URIMaxHeaders
+// are rejected with ErrURITooLong.
+// The uri is parsed in a single pass. When a '@' found inside what looked
+// like the parameters or headers shows that they were in fact part of the
+// user, the host part is restarted, but this happens at most once (after
+// it the user is known), so the parsing time is linear in the uri length.
 // TODO: tel uri emebeded in sip (user=phone param)
 // TODO: specific tel uri parser
 // TODO: parse important parameters like transport and user automatically
@@ -318,6 +372,10 @@ func ParseURI(uri SIPStr, puri *PsipURI) (ErrorURI, int) {
 	if len(uri) < 5 {
 		return ErrURITooShort, len(uri)
 	}
+	// the offsets must fit in a PField
+	if len(uri) > URIMaxLen || len(uri) > int(^OffsT(0)) {
+		return ErrURITooLong, 0
+	}
 	var offs int
 	var sch uint32
 	state := uInit
@@ -356,10 +414,38 @@ func ParseURI(uri SIPStr, puri *PsipURI) (ErrorURI, int) {
 	var passOffs int // possible password candidate offset
 	var portNo int
 	var errHeaders bool // possible header error
+	var nParams, nHdrs int
+	cs := offs // current component start, for URIMaxCompLen
 	i := offs
 	var c byte
 	for ; i < len(uri); i++ {
 		c = uri[i]
+		// check the component limits
+		switch c {
+		case ';':
+			nParams++
+			if nParams > URIMaxParams {
+				return ErrURITooLong, i
+			}
+			cs = i + 1
+		case '&':
+			nHdrs++
+			if nHdrs >= URIMaxHeaders { // '&' no = headers no - 1
+				return ErrURITooLong, i
+			}
+			cs = i + 1
+		case '@':
+			// end of the user part (any other '@' is an error), don't
+			// count its ';' and '&' as params or headers
+			nParams, nHdrs = 0, 0
+			cs = i + 1
+		case ':', '?', '=':
+			cs = i + 1
+		default:
+			if i-cs >= URIMaxCompLen {
+				return ErrURITooLong, i
+			}
+		}
 		switch state {
 		case uInitSIP, uInitSIPS, uInitTEL:
 			switch c {
@@ -431,7 +517,10 @@ func ParseURI(uri SIPStr, puri *PsipURI) (ErrorURI, int) {
 				}
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 				// in case this might be the port no, compute it
-				portNo = portNo*10 + int(c-'0')
+				// (stop before overflowing, anything > 65535 is invalid)
+				if portNo <= 65535 {
+					portNo = portNo*10 + int(c-'0')
+				}
 			case '[', ']', ':':
 				return ErrURIBadChar, i
 			default:
@@ -504,7 +593,9 @@ func ParseURI(uri SIPStr, puri *PsipURI) (ErrorURI, int) {
 		case uPort:
 			switch c {
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-				portNo = portNo*10 + int(c-'0')
+				if portNo <= 65535 { // avoid overflow
+					portNo = portNo*10 + int(c-'0')
+				}
 			case ';':
 				puri.Port.Set(s, i)
 				if portNo > 65535 {
@@ -0,0 +1,56 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE_BSD.txt file in the root of the source
+// tree.
+
+//go:build go1.18
+// +build go1.18
+
+package sipsp
+
+import (
+	"testing"
+)
+
+func FuzzParseURI(f *testing.F) {
+	seeds := [...]string{
+		"sip:a@foo.bar:5060;p1=1;p2=2?h1=1&h2=2",
+		"sips:foo.bar;p1=1;p2=2",
+		"sip:user;x=1:pass@foo.bar",
+		"sip:user?x:pass@foo.bar",
+		"sip:foo:18446744073709551617",
+		"sip:[2001:db8::1]:5060;lr",
+		"tel:+358-555-1234567;postd=pp22",
+		"sip:;:@:?@",
+		"sip:u;x:x:x:x:x@foo.bar;p=1?h=1&h=2",
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, uri []byte) {
+		var pu PsipURI
+		err, o := ParseURI(uri, &pu)
+		if o < 0 || o > len(uri) {
+			t.Fatalf("ParseURI(%q): offset %d out of range", uri, o)
+		}
+		if err != NoURIErr {
+			return
+		}
+		// all the fields must be inside uri
+		for _, f := range [...]PField{pu.Scheme, pu.User, pu.Pass, pu.Host,
+			pu.Port, pu.Params, pu.Headers} {
+			if int(f.Offs)+int(f.Len) > len(uri) {
+				t.Fatalf("ParseURI(%q): field %v out of range", uri, f)
+			}
+		}
+		if l := pu.Long(); int(l.Offs)+int(l.Len) > len(uri) {
+			t.Fatalf("ParseURI(%q): Long() %v out of range", uri, l)
+		}
+		// a uri must be equal to itself (params & headers are not
+		// validated by ParseURI())
+		if !URICmpShort(&pu, uri, &pu, uri, URICmpDefault) {
+			t.Fatalf("ParseURI(%q): uri not equal to itself", uri)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/intuitivelabs/bytescase"
+)
+
+// uriNormMaxParams is the maximum number of uri parameters supported by
+// WriteNormalized().
+const uriNormMaxParams = 16
+
+// uriNormW is a helper for writing into a fixed size buffer.
+type uriNormW struct {
+	dst []byte
+	n   int  // bytes written
+	ovf bool // not enough space in dst
+}
+
+func (w *uriNormW) addByte(c byte) {
+	if w.n >= len(w.dst) {
+		w.ovf = true
+		return
+	}
+	w.dst[w.n] = c
+	w.n++
+}
+
+func (w *uriNormW) addBytes(s []byte) {
+	if w.n+len(s) > len(w.dst) {
+		w.ovf = true
+		return
+	}
+	w.n += copy(w.dst[w.n:], s)
+}
+
+func (w *uriNormW) addLower(s []byte) {
+	for _, c := range s {
+		w.addByte(bytescase.ByteToLower(c))
+	}
+}
+
+// addEscaped adds s normalizing the escapes: escaped unreserved chars are
+// un-escaped and the hex digits of all the other escapes are uppercased.
+func (w *uriNormW) addEscaped(s []byte) {
+	const hexDigits = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) {
+			w.addByte(s[i])
+			continue
+		}
+		h, l := hexDigToI(s[i+1]), hexDigToI(s[i+2])
+		if h < 0 || l < 0 {
+			w.addByte(s[i]) // invalid escape, leave it alone
+			continue
+		}
+		c := byte(h<<4 | l)
+		if uriUnreserved(c) {
+			w.addByte(c)
+		} else {
+			w.addByte('%')
+			w.addByte(hexDigits[h])
+			w.addByte(hexDigits[l])
+		}
+		i += 2
+	}
+}
+
+// uriUnreserved returns true if c is an rfc3261 unreserved char
+// (alphanum / mark).
+func uriUnreserved(c byte) bool {
+	if (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') ||
+		(c >= 'a' && c <= 'z') {
+		return true
+	}
+	switch c {
+	case '-', '_', '.', '!', '~', '*', '\'', '(', ')':
+		return true
+	}
+	return false
+}
+
+// lowerCmp compares a and b case-insensitively (ASCII), returning
+// a negative number, 0 or a positive number (similar to bytes.Compare()).
+func lowerCmp(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := bytescase.ByteToLower(a[i]), bytescase.ByteToLower(b[i])
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// WriteNormalized writes a normalized form of the uri u (with the fields
+// pointing inside buf) into dst and returns the number of bytes written.
+// The normalized form has: a lowercase scheme and host, the user and
+// password escapes normalized (escaped unreserved chars are un-escaped and
+// the other escapes use uppercase hex digits), IPv6 hosts enclosed in
+// brackets, the port removed if it is the scheme default one (5060 for
+// sip and 5061 for sips), lowercase parameters sorted by name and the
+// headers unchanged
+// (e.g. sip:A@HOST:5060;Transport=TCP -> sip:A@host;transport=tcp).
+// Two uris that match with URICmp(..., URICmpDefPort) will in general have
+// the same normalized form, so it can be used for building canonical
+// keys (e.g. AORs).
+// It returns ErrURITooLong if dst is too small, ErrURIScheme for an
+// unknown uri scheme and ErrURIBad if the parameters cannot be parsed (or
+// there are too many of them).
+func (u *PsipURI) WriteNormalized(dst []byte, buf []byte) (int, error) {
+	w := uriNormW{dst: dst}
+	switch u.URIType {
+	case SIPuri:
+		w.addBytes([]byte("sip:"))
+	case SIPSuri:
+		w.addBytes([]byte("sips:"))
+	case TELuri:
+		w.addBytes([]byte("tel:"))
+	default:
+		return 0, ErrURIScheme
+	}
+	if !u.User.Empty() {
+		w.addEscaped(u.User.Get(buf))
+		if !u.Pass.Empty() {
+			w.addByte(':')
+			w.addEscaped(u.Pass.Get(buf))
+		}
+		if !u.Host.Empty() {
+			w.addByte('@')
+		}
+	}
+	if host := u.Host.Get(buf); len(host) > 0 {
+		v6 := host[0] != '[' && bytes.IndexByte(host, ':') >= 0
+		if v6 {
+			w.addByte('[')
+		}
+		w.addLower(host)
+		if v6 {
+			w.addByte(']')
+		}
+	}
+	if u.PortNo != 0 && u.PortNo != uriCmpPort(&PsipURI{URIType: u.URIType},
+		URICmpDefPort) {
+		var p [5]byte
+		w.addByte(':')
+		w.addBytes(strconv.AppendUint(p[:0], uint64(u.PortNo), 10))
+	}
+	if !u.Params.Empty() {
+		var pbuf [uriNormMaxParams]URIParam
+		var idx [uriNormMaxParams]int
+		var l URIParamsLst
+		params := u.Params.Get(buf)
+		l.Init(pbuf[:])
+		_, _, err := ParseAllURIParams(params, 0, &l, POptInputEndF)
+		if (err != 0 && err != ErrHdrEOH) || l.More() {
+			return w.n, ErrURIBad
+		}
+		// insertion sort by name
+		for i := 0; i < l.PNo(); i++ {
+			j := i
+			for ; j > 0 && lowerCmp(
+				l.Params[idx[j-1]].Param.Name.Get(params),
+				l.Params[i].Param.Name.Get(params)) > 0; j-- {
+				idx[j] = idx[j-1]
+			}
+			idx[j] = i
+		}
+		for i := 0; i < l.PNo(); i++ {
+			p := &l.Params[idx[i]].Param
+			w.addByte(';')
+			w.addLower(p.Name.Get(params))
+			if !p.Val.Empty() {
+				w.addByte('=')
+				w.addLower(p.Val.Get(params))
+			}
+		}
+	}
+	if !u.Headers.Empty() {
+		w.addByte('?')
+		w.addBytes(u.Headers.Get(buf))
+	}
+	if w.ovf {
+		return w.n, ErrURITooLong
+	}
+	return w.n, nil
+}
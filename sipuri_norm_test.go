@@ -0,0 +1,64 @@
+// Copyright 2022 Intuitive Labs GmbH. All rights reserved.
+//
+// Use of this source code is governed by a source-available license
+// that can be found in the LICENSE.txt file in the root of the source
+// tree.
+
+package sipsp
+
+import (
+	"testing"
+)
+
+func TestURIWriteNormalized(t *testing.T) {
+	type testCase struct {
+		uri  string
+		norm string
+		err  error
+	}
+	tests := [...]testCase{
+		{"sip:A@HOST:5060", "sip:A@host", nil},
+		{"SIP:A@Host.COM:5070", "sip:A@host.com:5070", nil},
+		{"sips:a@host:5061", "sips:a@host", nil},
+		{"sips:a@host:5060", "sips:a@host:5060", nil},
+		{"sip:%61li%63e:p%2fss@host", "sip:alice:p%2Fss@host", nil},
+		{"sip:a%zz@host", "sip:a%zz@host", nil},
+		{"sip:a@[2001:DB8::1]:5060", "sip:a@[2001:db8::1]", nil},
+		{"sip:host;Transport=TCP;lr;a=B", "sip:host;a=b;lr;transport=tcp",
+			nil},
+		{"sip:a@host;user=phone?Subject=Hi", "sip:a@host;user=phone?Subject=Hi",
+			nil},
+		{"tel:+1234;phone-context=X", "tel:+1234;phone-context=x", nil},
+	}
+	for _, tc := range tests {
+		var u PsipURI
+		buf := []byte(tc.uri)
+		if err, _ := ParseURI(buf, &u); err != NoURIErr {
+			t.Fatalf("ParseURI(%q) failed: %s", buf, err)
+		}
+		dst := make([]byte, 100)
+		n, err := u.WriteNormalized(dst, buf)
+		if err != tc.err || string(dst[:n]) != tc.norm {
+			t.Errorf("WriteNormalized(%q) = %q, %v expected %q, %v",
+				tc.uri, dst[:n], err, tc.norm, tc.err)
+		}
+		// not enough space
+		if _, err := u.WriteNormalized(dst[:len(tc.norm)-1], buf); err !=
+			ErrURITooLong {
+			t.Errorf("WriteNormalized(%q) into a too small buffer"+
+				" returned %v", tc.uri, err)
+		}
+	}
+	// equal uris have the same normalized form
+	var u1, u2 PsipURI
+	b1 := []byte("sip:bob@BILOXI.com;transport=udp;lr")
+	b2 := []byte("sip:bob@biloxi.com:5060;LR;Transport=UDP")
+	ParseURI(b1, &u1)
+	ParseURI(b2, &u2)
+	var d1, d2 [64]byte
+	n1, _ := u1.WriteNormalized(d1[:], b1)
+	n2, _ := u2.WriteNormalized(d2[:], b2)
+	if string(d1[:n1]) != string(d2[:n2]) {
+		t.Errorf("WriteNormalized: %q != %q", d1[:n1], d2[:n2])
+	}
+}
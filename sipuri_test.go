@@ -8,6 +8,7 @@ package sipsp
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -350,6 +351,48 @@ func TestUriCmp(t *testing.T) {
 			f:    0,
 			eRes: expR{true, 0, 0},
 		},
+		{
+			u1:   "sip:bob@biloxi.com",
+			u2:   "sips:bob@biloxi.com",
+			f:    0,
+			eRes: expR{false, 0, 0}, // diff scheme
+		},
+		{
+			u1:   "sip:bob@biloxi.com",
+			u2:   "sips:bob@biloxi.com",
+			f:    URICmpSIPSAsSIP,
+			eRes: expR{true, 0, 0}, // sip == sips
+		},
+		{
+			u1:   "sip:+358-555-1234567@biloxi.com",
+			u2:   "tel:+358-555-1234567",
+			f:    URICmpSIPSAsSIP,
+			eRes: expR{false, 0, 0}, // only sip & sips are equal
+		},
+		{
+			u1:   "sip:bob@biloxi.com:5060",
+			u2:   "sip:bob@biloxi.com",
+			f:    URICmpDefPort,
+			eRes: expR{true, 0, 0}, // default port
+		},
+		{
+			u1:   "sip:bob@biloxi.com:5061",
+			u2:   "sip:bob@biloxi.com",
+			f:    URICmpDefPort,
+			eRes: expR{false, 0, 0}, // not the default port
+		},
+		{
+			u1:   "sips:bob@biloxi.com:5061",
+			u2:   "sips:bob@biloxi.com",
+			f:    URICmpDefPort,
+			eRes: expR{true, 0, 0}, // sips default port
+		},
+		{
+			u1:   "sips:bob@biloxi.com",
+			u2:   "sip:bob@biloxi.com:5060",
+			f:    URICmpDefPort | URICmpSIPSAsSIP,
+			eRes: expR{false, 0, 0}, // diff default ports
+		},
 	}
 	for i, tc := range testCases {
 		res, err, n := URIRawCmp([]byte(tc.u1), []byte(tc.u2), tc.f)
@@ -374,3 +417,105 @@ func TestUriCmp(t *testing.T) {
 		}
 	}
 }
+
+func TestURICmpShortCase(t *testing.T) {
+	type testCase struct {
+		u1, u2 string
+		res    bool
+	}
+	tests := [...]testCase{
+		{"sip:bob@BILOXI.com", "sip:bob@biloxi.COM", true},  // host: no case
+		{"sip:Bob@biloxi.com", "sip:bob@biloxi.com", false}, // user: case
+		{"sip:bob:Pw@biloxi.com", "sip:bob:pw@biloxi.com", false},
+		{"sip:bob@biloxi.com", "sip:bob@biloxi.co", false},
+	}
+	for _, tc := range tests {
+		var u1, u2 PsipURI
+		b1 := []byte(tc.u1)
+		b2 := []byte(tc.u2)
+		if err, _ := ParseURI(b1, &u1); err != NoURIErr {
+			t.Fatalf("ParseURI(%q) failed: %d", b1, err)
+		}
+		if err, _ := ParseURI(b2, &u2); err != NoURIErr {
+			t.Fatalf("ParseURI(%q) failed: %d", b2, err)
+		}
+		if r := URICmpShort(&u1, b1, &u2, b2, 0); r != tc.res {
+			t.Errorf("URICmpShort(%q, %q) = %v expected %v",
+				tc.u1, tc.u2, r, tc.res)
+		}
+	}
+}
+
+func TestParseURILimits(t *testing.T) {
+	type testCase struct {
+		u   string
+		err ErrorURI
+	}
+	tests := [...]testCase{
+		// ports overflowing int64 used to wrap around to a "valid" port
+		{"sip:foo.bar:18446744073709551617", ErrURIPort},
+		{"sip:foo:18446744073709551617", ErrURIPort},
+		{"sip:foo:18446744073709551617;p", ErrURIPort},
+		{"sip:foo.bar:65536", ErrURIPort},
+		{"sip:foo.bar:65535", NoURIErr},
+		{"sip:" + strings.Repeat("a", int(^OffsT(0))), ErrURITooLong},
+		{"sip:" + strings.Repeat("a=", (int(^OffsT(0))-4)/2) + "a", NoURIErr},
+		// component limits
+		{"sip:" + strings.Repeat("a", URIMaxCompLen) + "@foo.bar", NoURIErr},
+		{"sip:" + strings.Repeat("a", URIMaxCompLen+1) + "@foo.bar",
+			ErrURITooLong},
+		{"sip:foo.bar;p=" + strings.Repeat("v", URIMaxCompLen+1),
+			ErrURITooLong},
+		{"sip:foo.bar?h=" + strings.Repeat("v", URIMaxCompLen+1),
+			ErrURITooLong},
+		{"sip:foo.bar" + strings.Repeat(";p=1", URIMaxParams), NoURIErr},
+		{"sip:foo.bar" + strings.Repeat(";p=1", URIMaxParams+1),
+			ErrURITooLong},
+		{"sip:foo.bar?h=1" + strings.Repeat("&h=1", URIMaxHeaders-1),
+			NoURIErr},
+		{"sip:foo.bar?h=1" + strings.Repeat("&h=1", URIMaxHeaders),
+			ErrURITooLong},
+		// ';' and '&' in the user part are not params or headers
+		{"sip:" + strings.Repeat("u;", URIMaxParams) +
+			strings.Repeat("u&", URIMaxHeaders-1) + "@foo.bar" +
+			strings.Repeat(";p=1", URIMaxParams) + "?h=1" +
+			strings.Repeat("&h=1", URIMaxHeaders-1), NoURIErr},
+		{"sip:u;x=1@foo.bar" + strings.Repeat(";p=1", URIMaxParams+1),
+			ErrURITooLong},
+		// repeated ':' and '@' (user / password / port re-classification)
+		{"sip:u;x=1@foo.bar;y", NoURIErr},
+		{"sip:u?x:p@foo.bar?y=1", NoURIErr},
+		{"sip:" + strings.Repeat("a:", 5000) + "@foo.bar", ErrURIBadChar},
+		{"sip:a;" + strings.Repeat(":@", 5000), ErrURIHost},
+		{"sip:u;x:p@" + strings.Repeat(":", 5000), ErrURIHost},
+		{"sip:u;" + strings.Repeat("x:", 5000) + "@foo.bar", ErrURIBadChar},
+		{"sip:u?" + strings.Repeat("x:", 5000) + "@foo.bar", ErrURIBadChar},
+	}
+	for _, tc := range tests {
+		var pu PsipURI
+		if err, _ := ParseURI([]byte(tc.u), &pu); err != tc.err {
+			t.Errorf("ParseURI(%.40q...) = %d (%s), expected %d (%s)",
+				tc.u, err, err, tc.err, tc.err)
+		}
+	}
+	// configurable limit
+	defer func(l int) { URIMaxLen = l }(URIMaxLen)
+	URIMaxLen = 16
+	var pu PsipURI
+	if err, _ := ParseURI([]byte("sip:foo@bar.com"), &pu); err != NoURIErr {
+		t.Errorf("ParseURI() with limit %d failed: %d (%s)",
+			URIMaxLen, err, err)
+	}
+	if err, _ := ParseURI([]byte("sip:foo@bar.com;x"), &pu); err != ErrURITooLong {
+		t.Errorf("ParseURI() with limit %d = %d (%s), expected %d (%s)",
+			URIMaxLen, err, err, ErrURITooLong, ErrURITooLong)
+	}
+	// the maximum PField offset is always enforced
+	URIMaxLen = int(^OffsT(0)) + 10
+	long := "sip:" + strings.Repeat("a=", int(^OffsT(0))/2)
+	if err, _ := ParseURI([]byte(long), &pu); err != ErrURITooLong {
+		t.Errorf("ParseURI(%d bytes) with limit %d = %d (%s), expected"+
+			" %d (%s)", len(long), URIMaxLen, err, err, ErrURITooLong,
+			ErrURITooLong)
+	}
+}